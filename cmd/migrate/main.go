@@ -5,26 +5,70 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"strings"
 
 	"github.com/edkadigital/startmeup/config"
+	"github.com/edkadigital/startmeup/migrations/app"
 	"github.com/edkadigital/startmeup/pkg/log"
 	"github.com/edkadigital/startmeup/pkg/migrations"
 	"github.com/edkadigital/startmeup/pkg/services"
 )
 
+const (
+	// exitPending is the exit code of the status and plan commands when migrations are pending
+	exitPending = 2
+)
+
 func main() {
 	// Parse command line flags
 	var (
 		migrateRiver   bool
-		forceRiver     bool
 		migrateSchemas bool
+		rollback       int
+		migrateTo      string
+		repair         bool
+		name           string
+		devURL         string
 	)
-	flag.BoolVar(&migrateRiver, "river", true, "Run River queue migrations")
-	flag.BoolVar(&forceRiver, "force-river", false, "Force applying River migrations regardless of what's already applied")
-	flag.BoolVar(&migrateSchemas, "schemas", true, "Run Ent schema migrations using Atlas")
-	flag.Parse()
+	flag.BoolVar(&migrateRiver, "river", true, "Run River queue and app data migrations")
+	flag.BoolVar(&migrateSchemas, "schemas", true, "Run Ent schema migrations")
+	flag.IntVar(&rollback, "rollback", 0, "Roll back the given number of most recently applied River migrations")
+	flag.StringVar(&migrateTo, "to", "", "Migrate River to the given version (ie, 003), applying or rolling back as needed; 0 rolls back everything")
+	flag.BoolVar(&repair, "repair", false, "Re-baseline the checksums of applied River migrations against the current files")
+	flag.StringVar(&name, "name", "", "Name of the migration to generate (ie, add_posts)")
+	flag.StringVar(&devURL, "dev-url", "", "Empty Postgres dev database used to generate migrations (defaults to the devConnection config)")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [command] [flags]\n\n", os.Args[0])
+		fmt.Fprintln(out, "Commands:")
+		fmt.Fprintln(out, "  apply           Apply pending migrations (default)")
+		fmt.Fprintln(out, "  status          List applied and pending migrations")
+		fmt.Fprintln(out, "  plan, dry-run   Print the SQL of pending migrations without executing it")
+		fmt.Fprintln(out, "  generate        Write a new Ent migration file from changes to ent/schema (requires -name)")
+		fmt.Fprintf(out, "\nstatus and plan exit with %d when migrations are pending, and 0 when there are none.\n\n", exitPending)
+		fmt.Fprintln(out, "Flags:")
+		flag.PrintDefaults()
+	}
+
+	// The command, if any, comes before the flags
+	command, args := "apply", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	switch command {
+	case "apply", "status", "plan", "dry-run":
+	case "generate":
+		generate(name, devURL)
+		return
+	default:
+		fmt.Printf("Unknown command: %s\n", command)
+		flag.Usage()
+		os.Exit(1)
+	}
 
 	// Start a new container to access the database
 	c := services.NewContainer()
@@ -35,75 +79,99 @@ func main() {
 		}
 	}()
 
-	// Run Ent schema migrations if requested
-	if migrateSchemas {
-		fmt.Println("Running Ent schema migrations using Atlas...")
-
-		// Construct the connection string for Atlas based on the environment
-		var dsn string
-		switch c.Config.App.Environment {
-		case config.EnvTest:
-			dsn = c.Config.Database.TestConnection
-		default:
-			dsn = c.Config.Database.Connection
+	// Create a River migrations manager, including the app data migrations which are ordered among them
+	riverManager := migrations.NewRiverManager(c.Database).
+		WithLockTimeout(c.Config.Database.MigrationLockTimeout)
+	if err := app.Register(riverManager); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Report on the migrations without running them if requested
+	if command != "apply" {
+		var sources []source
+		if migrateSchemas {
+			sources = append(sources, source{name: "Ent schema", planner: migrations.NewEntManager(c.Database)})
+		}
+		if migrateRiver {
+			sources = append(sources, source{name: "River", planner: riverManager})
+		}
+
+		if code := report(context.Background(), os.Stdout, command, sources); code != 0 {
+			os.Exit(code)
+		}
+		return
+	}
+
+	// Re-baseline applied River migrations if requested, without running any migrations
+	if repair {
+		if migrateRiver {
+			fmt.Println("Repairing River migration checksums...")
+			if err := riverManager.Repair(context.Background()); err != nil {
+				fmt.Printf("Error repairing River migrations: %v\n", err)
+				os.Exit(1)
+			}
 		}
 
-		// Validate DSN presence
-		if dsn == "" {
-			fmt.Println("Error: Database connection string (DSN) is empty for the current environment.")
+		fmt.Println("Migrations repaired!")
+		return
+	}
+
+	// Refuse to continue if applied River migrations were changed or removed
+	if migrateRiver {
+		if err := riverManager.Verify(context.Background()); err != nil {
+			fmt.Printf("Error verifying River migrations: %v\n", err)
+			fmt.Println("Restore the migration files, or run with -repair to accept them as they are.")
 			os.Exit(1)
 		}
+	}
 
-		// Determine the path to the migrations directory relative to the workspace root
-		migrationDir, err := filepath.Abs("ent/migrate/migrations")
-		if err != nil {
-			fmt.Printf("Error finding migration directory path: %v\n", err)
+	// Roll back or migrate River to a specific version if requested, without running any other migrations.
+	// Ent schema migrations are versioned by Atlas, which only applies them forwards.
+	if rollback > 0 || migrateTo != "" {
+		if !migrateRiver {
+			fmt.Println("Error: -rollback and -to only apply to River migrations, which are disabled by -river=false")
 			os.Exit(1)
 		}
 
-		// Ensure the migration directory exists
-		if _, err := os.Stat(migrationDir); os.IsNotExist(err) {
-			fmt.Printf("Migration directory does not exist: %s\nRun 'go generate ./ent/...' first to generate migrations.\n", migrationDir)
+		var err error
+		if rollback > 0 {
+			fmt.Printf("Rolling back %d River migration(s)...\n", rollback)
+			err = riverManager.Rollback(context.Background(), rollback)
+		} else {
+			fmt.Printf("Migrating River to version %s...\n", migrateTo)
+			err = riverManager.MigrateTo(context.Background(), migrateTo)
+		}
+
+		if err != nil {
+			fmt.Printf("Error migrating River: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Prepare the atlas command
-		// We use 'file://' prefix for the directory path
-		cmd := exec.Command("atlas", "migrate", "apply",
-			"--dir", fmt.Sprintf("file://%s", migrationDir),
-			"--url", dsn,
-		)
+		fmt.Println("River migrations completed successfully!")
+		return
+	}
 
-		// Capture output
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+	// Run Ent schema migrations if requested
+	if migrateSchemas {
+		fmt.Println("Running Ent schema migrations...")
 
-		// Run the command
-		if err := cmd.Run(); err != nil {
-			fmt.Printf("Error running Atlas migrations: %v\n", err)
+		entManager := migrations.NewEntManager(c.Database).
+			WithLockTimeout(c.Config.Database.MigrationLockTimeout)
+
+		if err := entManager.Apply(context.Background()); err != nil {
+			log.Default().Error("failed to run Ent schema migrations", "error", err)
 			os.Exit(1)
 		}
 
 		fmt.Println("Ent schema migrations completed!")
 	}
 
-	// Run River migrations if requested
+	// Run River and app data migrations if requested, once the schema the app migrations depend on is in place
 	if migrateRiver {
-		fmt.Println("Running River queue migrations...")
-
-		// Create a River migrations manager
-		riverManager := migrations.NewRiverManager(c.Database)
-
-		var err error
-		if forceRiver {
-			// Force apply all migrations
-			err = riverManager.ApplyAllMigrations(context.Background())
-		} else {
-			// Apply only pending migrations
-			err = riverManager.ApplyPendingMigrations(context.Background())
-		}
+		fmt.Println("Running River queue and app data migrations...")
 
-		if err != nil {
+		if err := riverManager.ApplyPendingMigrations(context.Background()); err != nil {
 			fmt.Printf("Error running River migrations: %v\n", err)
 			os.Exit(1)
 		}
@@ -113,3 +181,40 @@ func main() {
 
 	fmt.Println("All migrations completed!")
 }
+
+// generate writes a new Ent migration file, without needing the container or a connection to the app database
+func generate(name, devURL string) {
+	if name == "" {
+		fmt.Println("Error: -name is required, ie, migrate generate -name add_posts")
+		os.Exit(1)
+	}
+
+	if devURL == "" {
+		cfg, err := config.GetConfig()
+		if err != nil {
+			log.Default().Error("failed to load config", "error", err)
+			os.Exit(1)
+		}
+		devURL = cfg.Database.DevConnection
+	}
+
+	if devURL == "" {
+		fmt.Println("Error: a dev database is required, set -dev-url or the devConnection config")
+		os.Exit(1)
+	}
+
+	fmt.Println("Generating Ent schema migration...")
+
+	file, err := migrations.GenerateEntMigration(context.Background(), devURL, migrations.EntMigrationsDir, name)
+	if err != nil {
+		log.Default().Error("failed to generate Ent schema migration", "error", err)
+		os.Exit(1)
+	}
+
+	if file == "" {
+		fmt.Println("The Ent schema has no changes, no migration was generated.")
+		return
+	}
+
+	fmt.Printf("Generated %s/%s\n", migrations.EntMigrationsDir, file)
+}
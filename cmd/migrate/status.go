@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/edkadigital/startmeup/pkg/migrations"
+)
+
+// planner reports on the migrations of a single migration directory
+type planner interface {
+	Status(ctx context.Context) ([]migrations.Status, error)
+	Plan(ctx context.Context) ([]migrations.PlannedMigration, error)
+}
+
+// source is a named migration directory
+type source struct {
+	name    string
+	planner planner
+}
+
+// report runs the status or plan command, writing its output to out, and returns the exit code: 0 when no
+// migrations are pending, exitPending when some are, and 1 on error
+func report(ctx context.Context, out io.Writer, command string, sources []source) int {
+	var (
+		pending int
+		err     error
+	)
+	if command == "status" {
+		pending, err = printStatus(ctx, out, sources)
+	} else {
+		pending, err = printPlan(ctx, out, sources)
+	}
+
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	case pending > 0:
+		return exitPending
+	default:
+		return 0
+	}
+}
+
+// printStatus lists the applied and pending migrations of each source, returning how many are pending
+func printStatus(ctx context.Context, out io.Writer, sources []source) (int, error) {
+	var pending int
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	for _, src := range sources {
+		statuses, err := src.planner.Status(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get %s migration status: %w", src.name, err)
+		}
+
+		fmt.Fprintf(w, "%s migrations:\n", src.name)
+		for _, s := range statuses {
+			switch {
+			case s.Pending():
+				pending++
+				fmt.Fprintf(w, "  pending\t\t%s\n", s.Name)
+			case s.Missing:
+				fmt.Fprintf(w, "  applied\t%s\t%s (file missing)\n", s.AppliedAt.Format("2006-01-02 15:04:05 MST"), s.Name)
+			default:
+				fmt.Fprintf(w, "  applied\t%s\t%s\n", s.AppliedAt.Format("2006-01-02 15:04:05 MST"), s.Name)
+			}
+		}
+		if len(statuses) == 0 {
+			fmt.Fprintln(w, "  none")
+		}
+		fmt.Fprintln(w)
+	}
+
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+
+	fmt.Fprintf(out, "%d pending migration(s)\n", pending)
+	return pending, nil
+}
+
+// printPlan prints the SQL of the pending migrations of each source without executing it, returning how
+// many are pending
+func printPlan(ctx context.Context, out io.Writer, sources []source) (int, error) {
+	var pending int
+
+	for _, src := range sources {
+		plan, err := src.planner.Plan(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to plan %s migrations: %w", src.name, err)
+		}
+
+		for _, p := range plan {
+			pending++
+			fmt.Fprintf(out, "-- %s migration: %s\n%s\n\n", src.name, p.Name, p.SQL)
+		}
+	}
+
+	fmt.Fprintf(out, "-- %d pending migration(s)\n", pending)
+	return pending, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/migrations"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePlanner reports fixed migrations
+type fakePlanner struct {
+	statuses []migrations.Status
+	plan     []migrations.PlannedMigration
+	err      error
+}
+
+func (p fakePlanner) Status(context.Context) ([]migrations.Status, error) {
+	return p.statuses, p.err
+}
+
+func (p fakePlanner) Plan(context.Context) ([]migrations.PlannedMigration, error) {
+	return p.plan, p.err
+}
+
+func TestReport(t *testing.T) {
+	appliedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	upToDate := fakePlanner{
+		statuses: []migrations.Status{{Name: "001_first", AppliedAt: appliedAt}},
+	}
+	pending := fakePlanner{
+		statuses: []migrations.Status{
+			{Name: "9_ninth", AppliedAt: appliedAt},
+			{Name: "10_tenth"},
+			{Name: "008_removed", AppliedAt: appliedAt, Missing: true},
+		},
+		plan: []migrations.PlannedMigration{{Name: "10_tenth", SQL: "SELECT 10;"}},
+	}
+	failing := fakePlanner{err: errors.New("connection refused")}
+
+	tests := []struct {
+		name    string
+		command string
+		sources []source
+		code    int
+		output  string
+	}{
+		{
+			name:    "status up to date",
+			command: "status",
+			sources: []source{{name: "River", planner: upToDate}},
+			code:    0,
+			output: "River migrations:\n" +
+				"  applied  2025-06-01 12:00:00 UTC  001_first\n" +
+				"\n" +
+				"0 pending migration(s)\n",
+		},
+		{
+			name:    "status pending",
+			command: "status",
+			sources: []source{{name: "Ent schema", planner: fakePlanner{}}, {name: "River", planner: pending}},
+			code:    exitPending,
+			output: "Ent schema migrations:\n" +
+				"  none\n" +
+				"\n" +
+				"River migrations:\n" +
+				"  applied  2025-06-01 12:00:00 UTC  9_ninth\n" +
+				"  pending                           10_tenth\n" +
+				"  applied  2025-06-01 12:00:00 UTC  008_removed (file missing)\n" +
+				"\n" +
+				"1 pending migration(s)\n",
+		},
+		{
+			name:    "plan up to date",
+			command: "plan",
+			sources: []source{{name: "River", planner: upToDate}},
+			code:    0,
+			output:  "-- 0 pending migration(s)\n",
+		},
+		{
+			name:    "plan pending",
+			command: "dry-run",
+			sources: []source{{name: "River", planner: pending}},
+			code:    exitPending,
+			output: "-- River migration: 10_tenth\nSELECT 10;\n\n" +
+				"-- 1 pending migration(s)\n",
+		},
+		{
+			name:    "status error",
+			command: "status",
+			sources: []source{{name: "River", planner: failing}},
+			code:    1,
+			output:  "Error: failed to get River migration status: connection refused\n",
+		},
+		{
+			name:    "plan error",
+			command: "plan",
+			sources: []source{{name: "River", planner: failing}},
+			code:    1,
+			output:  "Error: failed to plan River migrations: connection refused\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.Equal(t, tc.code, report(context.Background(), &out, tc.command, tc.sources))
+			assert.Equal(t, tc.output, out.String())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/edkadigital/startmeup/pkg/seed"
+	"github.com/edkadigital/startmeup/pkg/services"
+)
+
+// main seeds the database with the fixtures of the current environment, and optionally fake data.
+func main() {
+	var (
+		dir  string
+		env  string
+		fake int
+	)
+	flag.StringVar(&dir, "dir", "seeds", "directory of the fixture files")
+	flag.StringVar(&env, "env", "", "fixture set to load (defaults to the app environment)")
+	flag.IntVar(&fake, "fake", 0, "number of fake users to create in addition to the fixtures")
+	flag.Parse()
+
+	// Start a new container.
+	c := services.NewContainer()
+	defer func() {
+		// Gracefully shutdown all services.
+		if err := c.Shutdown(); err != nil {
+			log.Default().Error("shutdown failed", "error", err)
+		}
+	}()
+
+	if env == "" {
+		env = string(c.Config.App.Environment)
+	}
+
+	if fake > 0 && c.Config.App.Environment == config.EnvProduction {
+		invalid("fake data cannot be seeded in production")
+	}
+
+	fixtures, err := seed.Load(os.DirFS(dir), env)
+	if err != nil {
+		invalid(err.Error())
+	}
+	fixtures.Users = append(fixtures.Users, seed.Fake(fake)...)
+
+	fmt.Printf("Seeding %d user(s) and %d password token(s) for the %s environment...\n",
+		len(fixtures.Users), len(fixtures.PasswordTokens), env)
+
+	res, err := seed.Seed(context.Background(), c.ORM, fixtures)
+	if err != nil {
+		invalid(err.Error())
+	}
+
+	fmt.Printf("Seeding completed: %s\n", res)
+}
+
+func invalid(msg string) {
+	fmt.Printf("[ERROR] %s\n", msg)
+	os.Exit(1)
+}
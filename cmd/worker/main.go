@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/edkadigital/startmeup/pkg/log"
@@ -12,6 +14,11 @@ import (
 )
 
 func main() {
+	// Parse command line flags
+	var queues string
+	flag.StringVar(&queues, "queues", "", "Comma-separated names of the queues to process tasks from (default: all configured queues)")
+	flag.Parse()
+
 	// Start a new container.
 	c := services.NewContainer()
 	defer func() {
@@ -22,11 +29,16 @@ func main() {
 	// Register all task workers.
 	tasks.Register(c)
 
+	// Only process tasks from the given queues, if any.
+	if queues != "" {
+		fatal("invalid queues", c.Tasks.SetQueues(strings.Split(strings.ReplaceAll(queues, " ", ""), ",")...))
+	}
+
 	// Start the worker to process tasks from queues.
 	log.Default().Info("Starting task worker")
 
-	// Start the River worker in the foreground
-	c.Tasks.Start(context.Background())
+	// Start the River worker in the background.
+	fatal("failed to start task worker", c.Tasks.Start(context.Background()))
 
 	// Wait for interrupt signal to gracefully shut down the task runner.
 	quit := make(chan os.Signal, 1)
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -98,9 +102,11 @@ type (
 
 	// DatabaseConfig stores the database configuration.
 	DatabaseConfig struct {
-		Driver         string
-		Connection     string
-		TestConnection string
+		Driver               string
+		Connection           string
+		TestConnection       string
+		DevConnection        string
+		MigrationLockTimeout time.Duration
 	}
 
 	// FilesConfig stores the file system configuration.
@@ -110,11 +116,13 @@ type (
 
 	// TasksConfig stores the tasks configuration.
 	TasksConfig struct {
-		Goroutines      int
+		Queues          map[string]int
 		ReleaseAfter    time.Duration
 		CleanupInterval time.Duration
 		ShutdownTimeout time.Duration
+		Timeout         time.Duration
 		PollInterval    time.Duration
+		PollOnly        bool
 	}
 
 	// MailConfig stores the mail configuration.
@@ -148,32 +156,98 @@ func GetConfig() (Config, error) {
 		return c, err
 	}
 
-	if err := viper.Unmarshal(&c); err != nil {
+	// Merge the overlays for the environment, which env variables still take precedence over.
+	if err := mergeOverlays(); err != nil {
+		return c, err
+	}
+
+	// Resolve *_FILE environment variables and secret references on a copy of the settings, so the
+	// references are resolved again each time the configuration is loaded.
+	settings := viper.AllSettings()
+	if err := resolveSettings(settings, ""); err != nil {
+		return c, err
+	}
+
+	resolved := viper.New()
+	if err := resolved.MergeConfigMap(settings); err != nil {
+		return c, err
+	}
+
+	if err := resolved.Unmarshal(&c); err != nil {
 		return c, err
 	}
 
 	// Override database config with environment variables if provided
-	overrideFromEnv(&c)
+	if err := overrideFromEnv(&c); err != nil {
+		return c, err
+	}
 
 	return c, nil
 }
 
-// overrideFromEnv overrides configuration with environment variables
-func overrideFromEnv(c *Config) {
+// mergeOverlays merges config.<environment>.yaml for the active environment, then config.local.yaml, from the
+// directory of the base config file if they exist. config.local.yaml is not committed and holds the overrides
+// of a single machine. It is skipped in the test environment to keep tests reproducible, and in the local
+// environment it is already the overlay of the environment.
+func mergeOverlays() error {
+	dir := filepath.Dir(viper.ConfigFileUsed())
+	env := environment(viper.GetString("app.environment"))
+
+	overlays := []string{fmt.Sprintf("config.%s.yaml", env)}
+	if env != EnvLocal && env != EnvTest {
+		overlays = append(overlays, "config.local.yaml")
+	}
+
+	for _, name := range overlays {
+		f, err := os.Open(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		err = viper.MergeConfig(f)
+		_ = f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// overrideFromEnv overrides configuration with environment variables, which can also be read from the file
+// named by the variable with a _FILE suffix, ie DATABASE_URL_FILE
+func overrideFromEnv(c *Config) error {
+	var errs []error
+	env := func(name string) string {
+		v, _, err := lookupEnv(name)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return v
+	}
+
 	// Database configuration
-	if dbDriver := os.Getenv("DB_DRIVER"); dbDriver != "" {
+	if dbDriver := env("DB_DRIVER"); dbDriver != "" {
 		c.Database.Driver = dbDriver
 	}
 
 	// Only use DATABASE_URL for connection string
-	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+	if dbURL := env("DATABASE_URL"); dbURL != "" {
 		c.Database.Connection = dbURL
 	}
 
 	// Also update test connection if not explicitly set
-	if os.Getenv("DB_TEST_CONNECTION") == "" && c.Database.TestConnection == "" {
-		c.Database.TestConnection = c.Database.Connection
-	} else if testConn := os.Getenv("DB_TEST_CONNECTION"); testConn != "" {
+	if testConn := env("DB_TEST_CONNECTION"); testConn != "" {
 		c.Database.TestConnection = testConn
+	} else if c.Database.TestConnection == "" {
+		c.Database.TestConnection = c.Database.Connection
 	}
+
+	if devConn := env("DB_DEV_CONNECTION"); devConn != "" {
+		c.Database.DevConnection = devConn
+	}
+
+	return errors.Join(errs...)
 }
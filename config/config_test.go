@@ -17,3 +17,25 @@ func TestGetConfig(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, env, cfg.App.Environment)
 }
+
+func TestGetConfig_Overlays(t *testing.T) {
+	SwitchEnvironment(EnvProduction)
+	cfg, err := GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://go.startmeup.dev", cfg.App.Host)
+
+	// Settings the overlay does not set keep the values of config.yaml.
+	assert.Equal(t, "admin", cfg.Mail.User)
+
+	// Env variables take precedence over the overlay.
+	t.Setenv("PAGODA_APP_HOST", "https://override.example.com")
+	cfg, err = GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "https://override.example.com", cfg.App.Host)
+
+	// Other environments only load config.yaml.
+	SwitchEnvironment(EnvStaging)
+	cfg, err = GetConfig()
+	require.NoError(t, err)
+	assert.NotEqual(t, "https://go.startmeup.dev", cfg.App.Host)
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SecretsDirEnv is the environment variable which sets the directory read by the secret:// provider.
+const SecretsDirEnv = "PAGODA_SECRETS_DIR"
+
+// DefaultSecretsDir is the directory read by the secret:// provider when SecretsDirEnv is not set.
+const DefaultSecretsDir = "/run/secrets"
+
+// SecretProvider resolves references to secrets, such as file:///run/secrets/db, which can be used in place of
+// any configuration value.
+type SecretProvider interface {
+	Resolve(ref *url.URL) (string, error)
+}
+
+// SecretProviderFunc adapts a function to a SecretProvider.
+type SecretProviderFunc func(ref *url.URL) (string, error)
+
+// Resolve calls the function.
+func (f SecretProviderFunc) Resolve(ref *url.URL) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"file":   FileSecretProvider{},
+		"env":    EnvSecretProvider{},
+		"secret": DirSecretProvider{},
+	}
+)
+
+// RegisterSecretProvider registers a provider for the references with the given URL scheme, replacing any
+// provider already registered for it.
+// This must be called prior to loading the configuration in order for it to take effect.
+func RegisterSecretProvider(scheme string, p SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[strings.ToLower(scheme)] = p
+}
+
+// FileSecretProvider resolves file:// references to the contents of a file, ie file:///run/secrets/db.
+type FileSecretProvider struct{}
+
+// Resolve reads the file.
+func (FileSecretProvider) Resolve(ref *url.URL) (string, error) {
+	return readSecretFile(ref.Host + ref.Path)
+}
+
+// EnvSecretProvider resolves env:// references to the value of an environment variable, ie env://DB_PASSWORD.
+type EnvSecretProvider struct{}
+
+// Resolve looks up the environment variable, which must be set.
+func (EnvSecretProvider) Resolve(ref *url.URL) (string, error) {
+	v, ok := os.LookupEnv(ref.Host)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref.Host)
+	}
+	return v, nil
+}
+
+// DirSecretProvider resolves references to the files of a directory, such as one where Kubernetes or Docker
+// mount secrets, ie secret://db-password reads db-password in the directory.
+type DirSecretProvider struct {
+	// Dir is the directory of the secret files, which defaults to SecretsDirEnv or DefaultSecretsDir.
+	Dir string
+}
+
+// Resolve reads the file of the secret, which cannot be outside the directory.
+func (p DirSecretProvider) Resolve(ref *url.URL) (string, error) {
+	name := strings.TrimPrefix(ref.Host+ref.Path, "/")
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("invalid secret name %q", name)
+	}
+
+	dir := p.Dir
+	if dir == "" {
+		dir = os.Getenv(SecretsDirEnv)
+	}
+	if dir == "" {
+		dir = DefaultSecretsDir
+	}
+
+	return readSecretFile(filepath.Join(dir, filepath.FromSlash(name)))
+}
+
+// readSecretFile returns the contents of a secret file, without the trailing newline editors and tools add.
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// resolveSecret returns the secret if the value is a reference with the scheme of a registered provider, and
+// otherwise the value unchanged.
+func resolveSecret(value string) (string, error) {
+	scheme, _, found := strings.Cut(value, "://")
+	if !found {
+		return value, nil
+	}
+
+	secretProvidersMu.RLock()
+	p, ok := secretProviders[strings.ToLower(scheme)]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		return value, nil
+	}
+
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret reference: %w", err)
+	}
+
+	secret, err := p.Resolve(ref)
+	if err != nil {
+		// The reference is left out as it may contain credentials.
+		return "", fmt.Errorf("failed to resolve %s:// secret: %w", ref.Scheme, err)
+	}
+	return secret, nil
+}
+
+// lookupEnv returns the value of an environment variable, or the contents of the file named by the variable
+// with a _FILE suffix, with any secret reference resolved.
+func lookupEnv(name string) (string, bool, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		path, fileOK := os.LookupEnv(name + "_FILE")
+		if !fileOK {
+			return "", false, nil
+		}
+
+		var err error
+		if v, err = readSecretFile(path); err != nil {
+			return "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+	}
+
+	v, err := resolveSecret(v)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, true, nil
+}
+
+// resolveSettings replaces, in place, each setting which has a PAGODA_<KEY>_FILE environment variable with the
+// contents of the file, and each secret reference with the secret. Every failure is reported at once.
+func resolveSettings(settings map[string]any, prefix string) error {
+	var errs []error
+
+	for key, value := range settings {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if nested, ok := value.(map[string]any); ok {
+			errs = append(errs, resolveSettings(nested, path))
+			continue
+		}
+
+		// Values set directly by environment variables are already in the settings
+		env := "PAGODA_" + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+		if file, ok := os.LookupEnv(env + "_FILE"); ok && os.Getenv(env) == "" {
+			v, err := readSecretFile(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to read %s_FILE: %w", env, err))
+				continue
+			}
+			value = v
+		}
+
+		switch v := value.(type) {
+		case string:
+			resolved, err := resolveSecret(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			settings[key] = resolved
+		case []any:
+			for i, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					continue
+				}
+				resolved, err := resolveSecret(s)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s.%d: %w", path, i, err))
+					continue
+				}
+				v[i] = resolved
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecret(t *testing.T, dir, name, value string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(value), 0600))
+	return path
+}
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	path := writeSecret(t, dir, "db", "s3cure\n")
+	t.Setenv(SecretsDirEnv, dir)
+	t.Setenv("TEST_SECRET", "from-env")
+
+	for value, expected := range map[string]string{
+		"file://" + path:                "s3cure",
+		"env://TEST_SECRET":             "from-env",
+		"secret://db":                   "s3cure",
+		"postgresql://u:p@localhost/db": "postgresql://u:p@localhost/db",
+		"plain":                         "plain",
+	} {
+		got, err := resolveSecret(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, got)
+	}
+
+	for _, value := range []string{"env://TEST_MISSING", "file:///missing", "secret://../db", "secret://missing"} {
+		_, err := resolveSecret(value)
+		assert.Error(t, err, value)
+	}
+
+	RegisterSecretProvider("vault", SecretProviderFunc(func(ref *url.URL) (string, error) {
+		return "vault:" + ref.Host + ref.Path, nil
+	}))
+	defer func() {
+		secretProvidersMu.Lock()
+		delete(secretProviders, "vault")
+		secretProvidersMu.Unlock()
+	}()
+
+	got, err := resolveSecret("vault://kv/app")
+	require.NoError(t, err)
+	assert.Equal(t, "vault:kv/app", got)
+}
+
+func TestGetConfig_Secrets(t *testing.T) {
+	dir := t.TempDir()
+	SwitchEnvironment(EnvTest)
+	t.Setenv("PAGODA_APP_ENCRYPTIONKEY", "file://"+writeSecret(t, dir, "key", "a-key-read-from-a-secret-file-0123"))
+	t.Setenv("PAGODA_MAIL_PASSWORD_FILE", writeSecret(t, dir, "mail", "mail-password\n"))
+	t.Setenv("DATABASE_URL_FILE", writeSecret(t, dir, "db", "postgresql://app:pw@db/app\n"))
+	t.Setenv("MAIL_USER_SECRET", "mailer")
+	t.Setenv("PAGODA_MAIL_USER", "env://MAIL_USER_SECRET")
+
+	cfg, err := GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "a-key-read-from-a-secret-file-0123", cfg.App.EncryptionKey)
+	assert.Equal(t, "mail-password", cfg.Mail.Password)
+	assert.Equal(t, "mailer", cfg.Mail.User)
+	assert.Equal(t, "postgresql://app:pw@db/app", cfg.Database.Connection)
+
+	// Unresolvable references fail to load.
+	t.Setenv("PAGODA_MAIL_USER", "env://MAIL_USER_MISSING")
+	_, err = GetConfig()
+	assert.ErrorContains(t, err, "mail.user")
+}
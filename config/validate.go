@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// sampleEncryptionKey is the encryption key committed in config.yaml, which must be changed on live environments.
+	sampleEncryptionKey = "?E(G+KbPeShVmYq3t6w9z$C&F)J@McQf"
+
+	// sampleDatabasePassword is the database password committed in config.yaml.
+	sampleDatabasePassword = "secret"
+
+	// sampleMailPassword is the mail password committed in config.yaml.
+	sampleMailPassword = "admin"
+
+	// minEncryptionKeyLength is the minimum length of the encryption key used to sign sessions and tokens.
+	minEncryptionKeyLength = 32
+
+	// minPasswordTokenLength and maxPasswordTokenLength bound the length of password tokens, which are hashed
+	// with bcrypt and so cannot be longer than 72 bytes.
+	minPasswordTokenLength = 16
+	maxPasswordTokenLength = 72
+)
+
+// ValidationError is returned when the configuration is invalid, and lists every problem found.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// validator collects the problems found while validating the configuration.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) positive(d time.Duration, key string) {
+	v.check(d > 0, "%s must be greater than zero", key)
+}
+
+// Validate checks that required values are set and within range, and that live environments do not use the
+// sample secrets or insecure connections. Every problem found is reported in a single ValidationError.
+func (c Config) Validate() error {
+	v := &validator{}
+
+	c.HTTP.validate(v)
+	c.App.validate(v)
+	c.Cache.validate(v)
+	c.Database.validate(v, c.App.Environment)
+	c.Files.validate(v)
+	c.Tasks.validate(v)
+	c.Mail.validate(v, c.App.Environment)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (c HTTPConfig) validate(v *validator) {
+	v.check(c.Port > 0, "http.port must be set")
+	v.check(c.ReadTimeout >= 0, "http.readTimeout cannot be negative")
+	v.check(c.WriteTimeout >= 0, "http.writeTimeout cannot be negative")
+	v.check(c.IdleTimeout >= 0, "http.idleTimeout cannot be negative")
+	v.positive(c.ShutdownTimeout, "http.shutdownTimeout")
+
+	if c.TLS.Enabled {
+		v.check(c.TLS.Certificate != "", "http.tls.certificate must be set when TLS is enabled")
+		v.check(c.TLS.Key != "", "http.tls.key must be set when TLS is enabled")
+	}
+}
+
+func (c AppConfig) validate(v *validator) {
+	v.check(c.Name != "", "app.name must be set")
+
+	host, err := url.Parse(c.Host)
+	v.check(err == nil && host.Scheme != "" && host.Host != "", "app.host must be an absolute URL, ie https://example.com")
+
+	switch c.Environment {
+	case EnvLocal, EnvTest, EnvDevelop, EnvStaging, EnvQA, EnvProduction:
+	default:
+		v.check(false, "app.environment %q is not one of local, test, dev, staging, qa or prod", c.Environment)
+	}
+
+	v.check(len(c.EncryptionKey) >= minEncryptionKeyLength,
+		"app.encryptionKey must be at least %d characters", minEncryptionKeyLength)
+	if c.Environment == EnvProduction {
+		v.check(c.EncryptionKey != sampleEncryptionKey, "app.encryptionKey cannot be the sample key in production")
+	}
+
+	v.positive(c.Timeout, "app.timeout")
+	v.positive(c.PasswordToken.Expiration, "app.passwordToken.expiration")
+	v.check(c.PasswordToken.Length >= minPasswordTokenLength && c.PasswordToken.Length <= maxPasswordTokenLength,
+		"app.passwordToken.length must be between %d and %d", minPasswordTokenLength, maxPasswordTokenLength)
+	v.positive(c.EmailVerificationTokenExpiration, "app.emailVerificationTokenExpiration")
+}
+
+func (c CacheConfig) validate(v *validator) {
+	v.check(c.Capacity > 0, "cache.capacity must be greater than zero")
+	v.check(c.Expiration.StaticFile >= 0, "cache.expiration.staticFile cannot be negative")
+}
+
+func (c DatabaseConfig) validate(v *validator, env environment) {
+	v.check(c.Driver != "", "database.driver must be set")
+	v.check(c.Connection != "", "database.connection must be set")
+	v.check(c.MigrationLockTimeout >= 0, "database.migrationLockTimeout cannot be negative")
+
+	if env != EnvProduction || c.Connection == "" {
+		return
+	}
+
+	conn, err := pgconn.ParseConfig(c.Connection)
+	if err != nil {
+		v.check(false, "database.connection is invalid: %v", err)
+		return
+	}
+
+	v.check(conn.Password != sampleDatabasePassword, "database.connection cannot use the sample password in production")
+	v.check(requiresTLS(conn) || isLocalHost(conn.Host),
+		"database.connection must use TLS in production (sslmode=require or stricter) unless the database is local")
+}
+
+// requiresTLS returns true if every connection attempt uses TLS. The default sslmode=prefer, like allow,
+// falls back to a plaintext connection when TLS fails.
+func requiresTLS(conn *pgconn.Config) bool {
+	if conn.TLSConfig == nil {
+		return false
+	}
+	for _, fallback := range conn.Fallbacks {
+		if fallback.TLSConfig == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c FilesConfig) validate(v *validator) {
+	v.check(c.Directory != "", "files.directory must be set")
+}
+
+func (c TasksConfig) validate(v *validator) {
+	v.check(len(c.Queues) > 0, "tasks.queues must contain at least one queue")
+	for queue, workers := range c.Queues {
+		v.check(workers > 0, "tasks.queues.%s must be greater than zero", queue)
+	}
+
+	v.positive(c.ReleaseAfter, "tasks.releaseAfter")
+	v.positive(c.CleanupInterval, "tasks.cleanupInterval")
+	v.positive(c.ShutdownTimeout, "tasks.shutdownTimeout")
+	v.positive(c.Timeout, "tasks.timeout")
+	v.positive(c.PollInterval, "tasks.pollInterval")
+}
+
+func (c MailConfig) validate(v *validator, env environment) {
+	v.check(c.Hostname != "", "mail.hostname must be set")
+	v.check(c.Port > 0, "mail.port must be set")
+
+	from, err := mail.ParseAddress(c.FromAddress)
+	v.check(err == nil, "mail.fromAddress must be an email address")
+
+	if env != EnvProduction {
+		return
+	}
+
+	// Only a relay on the same host can be trusted without credentials
+	v.check(isLocalHost(c.Hostname) || (c.User != "" && c.Password != ""),
+		"mail.user and mail.password must be set in production unless the mail server is local")
+	v.check(c.Password != sampleMailPassword, "mail.password cannot be the sample password in production")
+	if err == nil {
+		v.check(!strings.HasSuffix(from.Address, "@localhost"), "mail.fromAddress cannot be a localhost address in production")
+	}
+}
+
+// isLocalHost returns true if the host is a loopback address, localhost or a unix socket.
+func isLocalHost(host string) bool {
+	if host == "localhost" || strings.HasPrefix(host, "/") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
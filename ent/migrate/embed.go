@@ -0,0 +1,18 @@
+package migrate
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql migrations/atlas.sum
+var migrations embed.FS
+
+// Migrations returns the versioned Atlas migration directory embedded in the binary
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
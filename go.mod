@@ -3,6 +3,7 @@ module github.com/edkadigital/startmeup
 go 1.24.0
 
 require (
+	ariga.io/atlas v0.32.1
 	entgo.io/ent v0.14.4
 	github.com/PuerkitoBio/goquery v1.10.3
 	github.com/go-playground/validator/v10 v10.26.0
@@ -15,16 +16,19 @@ require (
 	github.com/maypok86/otter v1.2.4
 	github.com/riverqueue/river v0.20.2
 	github.com/riverqueue/river/riverdriver v0.20.2
+	github.com/riverqueue/river/riverdriver/riverdatabasesql v0.20.2
 	github.com/riverqueue/river/riverdriver/riverpgxv5 v0.20.2
+	github.com/riverqueue/river/rivertype v0.20.2
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/spf13/afero v1.14.0
 	github.com/spf13/viper v1.20.1
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/crypto v0.37.0
+	gopkg.in/yaml.v3 v3.0.1
 	maragu.dev/gomponents v1.1.0
 )
 
 require (
-	ariga.io/atlas v0.32.1 // indirect
 	ariga.io/atlas/cmd/atlas v0.13.1 // indirect
 	cloud.google.com/go v0.103.0 // indirect
 	cloud.google.com/go/compute v1.7.0 // indirect
@@ -111,7 +115,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20200410134404-eec4a21b6bb0 // indirect
 	github.com/riverqueue/river/rivershared v0.20.2 // indirect
-	github.com/riverqueue/river/rivertype v0.20.2 // indirect
 	github.com/rogpeppe/go-internal v1.12.0 // indirect
 	github.com/sagikazarmark/locafero v0.9.0 // indirect
 	github.com/sirupsen/logrus v1.8.1 // indirect
@@ -151,6 +154,5 @@ require (
 	google.golang.org/grpc v1.48.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 	nhooyr.io/websocket v1.8.7 // indirect
 )
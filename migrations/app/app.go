@@ -0,0 +1,42 @@
+// Package app registers the Go data migrations of the application with the River migrations manager.
+package app
+
+import (
+	"fmt"
+
+	"github.com/edkadigital/startmeup/pkg/migrations"
+)
+
+// migration is a Go migration and the name it is ordered and tracked by
+type migration struct {
+	name string
+	up   migrations.MigrationFunc
+	down migrations.MigrationFunc
+}
+
+// registered are the Go data migrations, which are applied in order of their versions alongside the River SQL
+// files, so they can rely on the River tables that precede them.
+//
+// For example, to backfill a field through the ORM:
+//
+//	{
+//		name: "20250601120000_backfill_user_names",
+//		up: func(ctx context.Context, tx *sql.Tx, orm *ent.Client) error {
+//			return orm.User.Update().
+//				Where(user.NameEQ("")).
+//				SetName("Unknown").
+//				Exec(ctx)
+//		},
+//	},
+var registered = []migration{}
+
+// Register adds the Go data migrations to the given manager, which should be the River migrations manager
+// so that every migration outside of the Ent schema is ordered and tracked in one place
+func Register(m *migrations.Manager) error {
+	for _, mig := range registered {
+		if err := m.Register(mig.name, mig.up, mig.down); err != nil {
+			return fmt.Errorf("failed to register app migration: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed river/*.sql
+var river embed.FS
+
+// River returns the River queue migrations embedded in the binary
+func River() fs.FS {
+	sub, err := fs.Sub(river, "river")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
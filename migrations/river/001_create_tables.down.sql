@@ -0,0 +1,11 @@
+-- Migration: 001_create_tables.down.sql
+-- River Queue Schema - Reverts the initial migration.
+--
+-- The river_migrations table is left in place since it tracks every migration
+-- in this directory. The pgcrypto extension is also kept as other schemas may
+-- rely on it.
+
+DROP TABLE IF EXISTS river_job_periodic;
+DROP TABLE IF EXISTS river_leader_clock;
+DROP TABLE IF EXISTS river_client_info;
+DROP TABLE IF EXISTS river_jobs;
@@ -1,4 +1,4 @@
--- Migration: 001_create_tables.sql
+-- Migration: 001_create_tables.up.sql
 -- River Queue Schema - Initial Migration
 
 -- Create extension for UUID generation if not exists
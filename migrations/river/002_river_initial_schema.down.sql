@@ -0,0 +1,8 @@
+-- Migration: 002_river_initial_schema.down.sql
+-- River Queue Schema - Reverts River's main migration line, versions 001 to 003.
+
+DROP TABLE river_leader;
+DROP TABLE river_job;
+DROP FUNCTION IF EXISTS river_job_notify;
+DROP TYPE river_job_state;
+DROP TABLE river_migration;
@@ -0,0 +1,60 @@
+-- Migration: 004_river_migration_unique_client.down.sql
+-- River Queue Schema - Reverts River's main migration line, version 005.
+
+--
+-- Revert to migration table based only on `(version)`.
+--
+-- If any non-main migrations are present, this migration is considered
+-- irreversible.
+--
+
+DO
+$body$
+BEGIN
+    IF (SELECT to_regclass('river_migration') IS NOT NULL) THEN
+        IF EXISTS (
+            SELECT *
+            FROM river_migration
+            WHERE line <> 'main'
+        ) THEN
+            RAISE EXCEPTION 'Found non-main migration lines in the database; version 005 migration is irreversible because it would result in loss of migration information.';
+        END IF;
+
+        DELETE FROM river_migration WHERE line = 'main' AND version = 5;
+
+        ALTER TABLE river_migration
+            RENAME TO river_migration_old;
+
+        CREATE TABLE river_migration(
+            id bigserial PRIMARY KEY,
+            created_at timestamptz NOT NULL DEFAULT NOW(),
+            version bigint NOT NULL,
+            CONSTRAINT version CHECK (version >= 1)
+        );
+
+        CREATE UNIQUE INDEX ON river_migration USING btree(version);
+
+        INSERT INTO river_migration
+            (created_at, version)
+        SELECT created_at, version
+        FROM river_migration_old;
+
+        DROP TABLE river_migration_old;
+    END IF;
+END;
+$body$
+LANGUAGE 'plpgsql';
+
+--
+-- Drop `river_job.unique_key`.
+--
+
+ALTER TABLE river_job
+    DROP COLUMN unique_key;
+
+--
+-- Drop `river_client` and derivative.
+--
+
+DROP TABLE river_client_queue;
+DROP TABLE river_client;
@@ -0,0 +1,45 @@
+-- Migration: 006_drop_unused_tables.down.sql
+-- River Queue Schema - Recreates the unused tables of 001_create_tables.up.sql,
+-- without the jobs they held.
+
+CREATE TABLE IF NOT EXISTS river_jobs (
+    id UUID PRIMARY KEY,
+    args JSONB NOT NULL,
+    attempt INT NOT NULL DEFAULT 0,
+    attempt_total INT NOT NULL DEFAULT 0,
+    attempted_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    discard_reason TEXT,
+    error JSONB,
+    finalizer_priority INT,
+    finalizer_retry_at TIMESTAMPTZ,
+    finalizer_total_attempt_limit INT,
+    finalized_at TIMESTAMPTZ,
+    kind TEXT NOT NULL,
+    max_attempts INT NOT NULL DEFAULT 25,
+    metadata JSONB,
+    priority INT NOT NULL DEFAULT 0,
+    queue TEXT NOT NULL DEFAULT 'default',
+    scheduled_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    state TEXT NOT NULL DEFAULT 'available',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    worker_id TEXT
+);
+
+CREATE INDEX IF NOT EXISTS river_jobs_kind_idx ON river_jobs (kind);
+CREATE INDEX IF NOT EXISTS river_jobs_queue_idx ON river_jobs (queue);
+CREATE INDEX IF NOT EXISTS river_jobs_scheduled_at_idx ON river_jobs (scheduled_at);
+CREATE INDEX IF NOT EXISTS river_jobs_state_idx ON river_jobs (state);
+CREATE INDEX IF NOT EXISTS river_jobs_updated_at_idx ON river_jobs (updated_at);
+
+CREATE TABLE IF NOT EXISTS river_client_info (
+    id TEXT PRIMARY KEY,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS river_leader_clock (
+    id INT PRIMARY KEY,
+    timestamp TIMESTAMPTZ NOT NULL
+);
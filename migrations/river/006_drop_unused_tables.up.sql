@@ -0,0 +1,9 @@
+-- Migration: 006_drop_unused_tables.up.sql
+-- River Queue Schema - Drops the tables created by 001_create_tables.up.sql which
+-- the River client never used, as it operates on the tables of River's own schema
+-- from 002 onwards. river_job_periodic is kept since it records when periodic
+-- tasks were last inserted, and pgcrypto since other schemas may rely on it.
+
+DROP TABLE IF EXISTS river_jobs;
+DROP TABLE IF EXISTS river_client_info;
+DROP TABLE IF EXISTS river_leader_clock;
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/edkadigital/startmeup/pkg/form"
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/edkadigital/startmeup/pkg/middleware"
+	"github.com/edkadigital/startmeup/pkg/msg"
+	"github.com/edkadigital/startmeup/pkg/pager"
+	"github.com/edkadigital/startmeup/pkg/redirect"
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/services"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
+	"github.com/edkadigital/startmeup/pkg/ui/forms"
+	"github.com/edkadigital/startmeup/pkg/ui/pages"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// adminTasksPerPage is the amount of tasks to list per page.
+const adminTasksPerPage = 25
+
+type AdminTask struct {
+	tasks *riveradapter.Worker
+}
+
+func init() {
+	Register(new(AdminTask))
+}
+
+func (h *AdminTask) Init(c *services.Container) error {
+	h.tasks = c.Tasks
+	return nil
+}
+
+func (h *AdminTask) Routes(g *echo.Group) {
+	tasks := g.Group("/admin/tasks", middleware.RequireAdmin)
+	tasks.GET("", h.List).Name = routenames.AdminTasks
+	tasks.POST("", h.ListSubmit).Name = routenames.AdminTasksSubmit
+	tasks.GET("/queues", h.Queues).Name = routenames.AdminTaskQueues
+	tasks.GET("/:id", h.View).Name = routenames.AdminTask
+	tasks.POST("/:id", h.ViewSubmit).Name = routenames.AdminTaskSubmit
+}
+
+func (h *AdminTask) List(ctx echo.Context) error {
+	filter := riveradapter.JobFilter{
+		State: ctx.QueryParam("state"),
+		Kind:  ctx.QueryParam("kind"),
+		Queue: ctx.QueryParam("queue"),
+	}
+
+	// Ignore unknown states, which the database cannot compare jobs against.
+	if !slices.Contains(riveradapter.JobStates(), filter.State) {
+		filter.State = ""
+	}
+
+	pgr := pager.NewPager(ctx, adminTasksPerPage)
+	jobs, total, err := h.tasks.ListJobs(ctx.Request().Context(), filter, pgr.ItemsPerPage, pgr.GetOffset())
+	if err != nil {
+		return fail(err, "failed to list tasks")
+	}
+	pgr.SetItems(total)
+
+	depths, err := h.tasks.QueueDepths(ctx.Request().Context())
+	if err != nil {
+		return fail(err, "failed to load queue depths")
+	}
+
+	return pages.AdminTaskList(ctx, filter, jobs, depths, pgr)
+}
+
+func (h *AdminTask) ListSubmit(ctx echo.Context) error {
+	var input forms.AdminTaskAction
+
+	err := form.Submit(ctx, &input)
+
+	switch err.(type) {
+	case nil:
+		if len(input.IDs) == 0 {
+			msg.Warning(ctx, "No tasks were selected.")
+		} else {
+			h.apply(ctx, input.Action, input.IDs...)
+		}
+	case validator.ValidationErrors:
+		msg.Danger(ctx, "Invalid action.")
+	default:
+		return err
+	}
+
+	// Return to the list with the same filters applied.
+	return redirect.
+		New(ctx).
+		Route(routenames.AdminTasks).
+		Query(ctx.QueryParams()).
+		StatusCode(http.StatusFound).
+		Go()
+}
+
+func (h *AdminTask) Queues(ctx echo.Context) error {
+	depths, err := h.tasks.QueueDepths(ctx.Request().Context())
+	if err != nil {
+		return fail(err, "failed to load queue depths")
+	}
+
+	return pages.AdminTaskQueues(ctx, depths)
+}
+
+func (h *AdminTask) View(ctx echo.Context) error {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid task ID")
+	}
+
+	job, err := h.tasks.GetJob(ctx.Request().Context(), id)
+	switch {
+	case err == nil:
+		return pages.AdminTask(ctx, job)
+	case errors.Is(err, riveradapter.ErrJobNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "task not found")
+	default:
+		return fail(err, "failed to load task")
+	}
+}
+
+func (h *AdminTask) ViewSubmit(ctx echo.Context) error {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid task ID")
+	}
+
+	var input forms.AdminTaskAction
+
+	err = form.Submit(ctx, &input)
+
+	switch err.(type) {
+	case nil:
+		h.apply(ctx, input.Action, id)
+	case validator.ValidationErrors:
+		msg.Danger(ctx, "Invalid action.")
+	default:
+		return err
+	}
+
+	return redirect.
+		New(ctx).
+		Route(routenames.AdminTask).
+		Params(id).
+		StatusCode(http.StatusFound).
+		Go()
+}
+
+// apply applies an action to the given tasks and sets a flash message with the outcome.
+func (h *AdminTask) apply(ctx echo.Context, action string, ids ...int64) {
+	var (
+		err     error
+		count   int
+		done    string
+		skipped string
+	)
+
+	switch action {
+	case forms.AdminTaskActionRetry:
+		count, err = h.tasks.RetryJobs(ctx.Request().Context(), ids...)
+		done = "retried"
+		skipped = "Running tasks cannot be retried."
+	case forms.AdminTaskActionCancel:
+		count, err = h.tasks.CancelJobs(ctx.Request().Context(), ids...)
+		done = "cancelled"
+		skipped = "Finalized tasks cannot be cancelled."
+	case forms.AdminTaskActionDiscard:
+		count, err = h.tasks.DiscardJobs(ctx.Request().Context(), ids...)
+		done = "discarded"
+		skipped = "Running and finalized tasks cannot be discarded."
+	}
+
+	switch {
+	case err != nil:
+		log.Ctx(ctx).Error("failed to apply task action",
+			"action", action,
+			"ids", ids,
+			"error", err,
+		)
+		msg.Danger(ctx, fmt.Sprintf("Not every task could be %s, please try again.", done))
+	case count < len(ids):
+		msg.Warning(ctx, fmt.Sprintf("%d of %d tasks %s. %s", count, len(ids), done, skipped))
+	default:
+		msg.Success(ctx, fmt.Sprintf("%d task(s) %s.", count, done))
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/edkadigital/startmeup/pkg/middleware"
+	"github.com/edkadigital/startmeup/pkg/msg"
+	"github.com/edkadigital/startmeup/pkg/tasks"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
+	"github.com/edkadigital/startmeup/pkg/tests"
+	"github.com/edkadigital/startmeup/pkg/ui/forms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminTask_List(t *testing.T) {
+	mem := c.Tasks.Memory()
+	mem.Reset()
+
+	_, err := riveradapter.Insert(t.Context(), c.Tasks, tasks.ExampleTask{Message: "hello"})
+	require.NoError(t, err)
+
+	h := new(AdminTask)
+	require.NoError(t, h.Init(c))
+
+	cases := []struct {
+		state string
+		found bool
+	}{
+		{state: "", found: true},
+		{state: riveradapter.JobStateAvailable, found: true},
+		{state: riveradapter.JobStateCompleted, found: false},
+		// Unknown states are ignored rather than passed to the database.
+		{state: "bogus", found: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.state, func(t *testing.T) {
+			ctx, rec := tests.NewContext(c.Web, "/admin/tasks?state="+tc.state)
+			tests.InitSession(ctx)
+			require.NoError(t, tests.ExecuteHandler(ctx, h.List, middleware.Config(c.Config)))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tc.found, strings.Contains(rec.Body.String(), tasks.ExampleTask{}.Kind()))
+		})
+	}
+}
+
+func TestAdminTask_Apply(t *testing.T) {
+	mem := c.Tasks.Memory()
+	mem.Reset()
+
+	h := new(AdminTask)
+	require.NoError(t, h.Init(c))
+
+	// Errors are logged rather than shown.
+	ctx, _ := tests.NewContext(c.Web, "/admin/tasks")
+	tests.InitSession(ctx)
+	h.apply(ctx, forms.AdminTaskActionRetry, 999)
+	assert.Equal(t, []string{"Not every task could be retried, please try again."}, msg.Get(ctx, msg.TypeDanger))
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"strings"
 
@@ -15,6 +16,8 @@ import (
 	"github.com/edkadigital/startmeup/pkg/redirect"
 	"github.com/edkadigital/startmeup/pkg/routenames"
 	"github.com/edkadigital/startmeup/pkg/services"
+	"github.com/edkadigital/startmeup/pkg/tasks"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
 	"github.com/edkadigital/startmeup/pkg/ui/emails"
 	"github.com/edkadigital/startmeup/pkg/ui/forms"
 	"github.com/edkadigital/startmeup/pkg/ui/pages"
@@ -27,6 +30,8 @@ type Auth struct {
 	auth   *services.AuthClient
 	mail   *services.MailClient
 	orm    *ent.Client
+	db     *sql.DB
+	tasks  *riveradapter.Worker
 }
 
 func init() {
@@ -38,6 +43,8 @@ func (h *Auth) Init(c *services.Container) error {
 	h.orm = c.ORM
 	h.auth = c.Auth
 	h.mail = c.Mail
+	h.db = c.Database
+	h.tasks = c.Tasks
 	return nil
 }
 
@@ -209,13 +216,26 @@ func (h *Auth) RegisterSubmit(ctx echo.Context) error {
 		return err
 	}
 
-	// Attempt creating the user.
-	u, err := h.orm.User.
-		Create().
-		SetName(input.Name).
-		SetEmail(input.Email).
-		SetPassword(input.Password).
-		Save(ctx.Request().Context())
+	// Attempt creating the user along with the task to welcome them, in a single transaction, so the email
+	// is only sent if the user is saved.
+	var u *ent.User
+	err = services.WithTx(ctx.Request().Context(), h.db, func(tx *services.Tx) error {
+		var err error
+		u, err = tx.ORM.User.
+			Create().
+			SetName(input.Name).
+			SetEmail(input.Email).
+			SetPassword(input.Password).
+			Save(ctx.Request().Context())
+		if err != nil {
+			return err
+		}
+
+		_, err = riveradapter.InsertTx(ctx.Request().Context(), h.tasks, tx.SQL,
+			tasks.SendWelcomeEmailTask{UserID: u.ID},
+		)
+		return err
+	})
 
 	switch err.(type) {
 	case nil:
@@ -68,7 +68,7 @@ func request(t *testing.T) *httpRequest {
 }
 
 func (h *httpRequest) setRoute(route string, params ...any) *httpRequest {
-	h.route = srv.URL + c.Web.Reverse(route, params)
+	h.route = srv.URL + c.Web.Reverse(route, params...)
 	return h
 }
 
@@ -1,21 +1,38 @@
 package handlers
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/edkadigital/startmeup/pkg/msg"
+	"github.com/edkadigital/startmeup/pkg/redirect"
 	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/session"
 	"github.com/edkadigital/startmeup/pkg/ui/forms"
 	"github.com/edkadigital/startmeup/pkg/ui/pages"
 
 	"github.com/edkadigital/startmeup/pkg/form"
 	"github.com/edkadigital/startmeup/pkg/services"
+	"github.com/edkadigital/startmeup/pkg/tasks"
 	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	// taskSessionName and taskSessionKeySubmitter store the ID which identifies who submitted a task, so that
+	// only they can view its status.
+	taskSessionName         = "task"
+	taskSessionKeySubmitter = "submitter"
+
+	// taskMetadataSubmitter is the job metadata key the submitter ID is stored under.
+	taskMetadataSubmitter = "submitter"
+)
+
 type Task struct {
 	tasks *riveradapter.Worker
 }
@@ -32,6 +49,7 @@ func (h *Task) Init(c *services.Container) error {
 func (h *Task) Routes(g *echo.Group) {
 	g.GET("/task", h.Page).Name = routenames.Task
 	g.POST("/task", h.Submit).Name = routenames.TaskSubmit
+	g.GET("/task/:id", h.Status).Name = routenames.TaskStatus
 }
 
 func (h *Task) Page(ctx echo.Context) error {
@@ -51,15 +69,89 @@ func (h *Task) Submit(ctx echo.Context) error {
 		return err
 	}
 
-	// Insert the task
-	err = h.tasks.InsertExampleTask(ctx.Request().Context(), input.Message, time.Duration(input.Delay)*time.Second)
-
+	submitter, err := h.submitter(ctx, true)
 	if err != nil {
+		return fail(err, "unable to load the task session")
+	}
+
+	// Insert the task, unless an identical one is still waiting to run, so repeated submissions do not
+	// result in duplicate tasks.
+	res, err := riveradapter.Insert(ctx.Request().Context(), h.tasks,
+		tasks.ExampleTask{Message: input.Message},
+		riveradapter.Delay(time.Duration(input.Delay)*time.Second),
+		riveradapter.Unique(riveradapter.UniqueByArgs(), riveradapter.UniqueWhileUnfinished()),
+		riveradapter.Metadata(map[string]any{taskMetadataSubmitter: submitter}),
+	)
+
+	switch {
+	case err != nil:
 		return fail(err, "unable to create a task")
+	case res.Duplicate:
+		msg.Warning(ctx, "An identical task is already in the queue.")
+		return h.Page(ctx)
+	}
+
+	msg.Success(ctx, "The task has been created.")
+
+	return redirect.New(ctx).
+		Route(routenames.TaskStatus).
+		Params(res.ID).
+		Go()
+}
+
+func (h *Task) Status(ctx echo.Context) error {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid task ID")
+	}
+
+	job, err := h.tasks.GetJob(ctx.Request().Context(), id)
+	switch {
+	case errors.Is(err, riveradapter.ErrJobNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, "task not found")
+	case err != nil:
+		return fail(err, "failed to load task")
+	}
+
+	// Only the submitter can view the task, and any other job is treated as not existing so that IDs cannot
+	// be probed.
+	submitter, err := h.submitter(ctx, false)
+	if err != nil {
+		return fail(err, "unable to load the task session")
+	}
+	if submitter == "" || job.Kind != (tasks.ExampleTask{}).Kind() || jobSubmitter(job) != submitter {
+		return echo.NewHTTPError(http.StatusNotFound, "task not found")
 	}
 
-	msg.Success(ctx, fmt.Sprintf("The task has been created. Check the logs in %d seconds.", input.Delay))
-	form.Clear(ctx)
+	return pages.TaskStatus(ctx, job)
+}
 
-	return h.Page(ctx)
+// submitter returns the ID of the current session, used to identify who submitted a task. If the session
+// has no ID yet, one is only created when requested.
+func (h *Task) submitter(ctx echo.Context, create bool) (string, error) {
+	sess, err := session.Get(ctx, taskSessionName)
+	if err != nil {
+		return "", err
+	}
+
+	if id, ok := sess.Values[taskSessionKeySubmitter].(string); ok && id != "" {
+		return id, nil
+	}
+	if !create {
+		return "", nil
+	}
+
+	id := uuid.NewString()
+	sess.Values[taskSessionKeySubmitter] = id
+	return id, sess.Save(ctx.Request(), ctx.Response())
+}
+
+// jobSubmitter returns the submitter ID stored in the metadata of a job, if any.
+func jobSubmitter(job *riveradapter.Job) string {
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(job.Metadata), &metadata); err != nil {
+		return ""
+	}
+	id, _ := metadata[taskMetadataSubmitter].(string)
+	return id
 }
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	goctx "context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/edkadigital/startmeup/ent"
+	"github.com/edkadigital/startmeup/pkg/context"
+	"github.com/edkadigital/startmeup/pkg/middleware"
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/tasks"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
+	"github.com/edkadigital/startmeup/pkg/tests"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTask_Submit(t *testing.T) {
+	mem := c.Tasks.Memory()
+	mem.Reset()
+
+	h := new(Task)
+	require.NoError(t, h.Init(c))
+
+	submit := func(message string) *httptest.ResponseRecorder {
+		body := url.Values{"message": {message}, "delay": {"0"}}
+		req := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(body.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		ctx := c.Web.NewContext(req, rec)
+		tests.InitSession(ctx)
+		require.NoError(t, tests.ExecuteHandler(ctx, h.Submit, middleware.Config(c.Config)))
+		return rec
+	}
+
+	rec := submit("hello")
+	assert.Equal(t, http.StatusTemporaryRedirect, rec.Code)
+	assert.Equal(t, []tasks.ExampleTask{{Message: "hello"}}, riveradapter.Enqueued[tasks.ExampleTask](mem))
+
+	jobs := mem.Jobs()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, c.Web.Reverse(routenames.TaskStatus, jobs[0].ID), rec.Header().Get(echo.HeaderLocation))
+
+	// An identical task is not inserted while the first is still waiting to run.
+	submit("hello")
+	assert.Len(t, mem.Jobs(), 1)
+
+	submit("world")
+	assert.Len(t, riveradapter.Enqueued[tasks.ExampleTask](mem), 2)
+}
+
+func TestTask_Status(t *testing.T) {
+	mem := c.Tasks.Memory()
+	mem.Reset()
+
+	h := new(Task)
+	require.NoError(t, h.Init(c))
+
+	// Submit a task, keeping the session cookie of the submitter.
+	body := url.Values{"message": {"hello"}, "delay": {"0"}}
+	req := httptest.NewRequest(http.MethodPost, "/task", strings.NewReader(body.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	rec := httptest.NewRecorder()
+	ctx := c.Web.NewContext(req, rec)
+	tests.InitSession(ctx)
+	require.NoError(t, tests.ExecuteHandler(ctx, h.Submit, middleware.Config(c.Config)))
+	cookies := rec.Result().Cookies()
+
+	jobs := mem.Jobs()
+	require.Len(t, jobs, 1)
+
+	view := func(id int64, withSession bool, user *ent.User) (string, error) {
+		ctx, rec := tests.NewContext(c.Web, "/")
+		if withSession {
+			for _, cookie := range cookies {
+				ctx.Request().AddCookie(cookie)
+			}
+		}
+		if user != nil {
+			ctx.Set(context.AuthenticatedUserKey, user)
+		}
+		tests.InitSession(ctx)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(fmt.Sprint(id))
+		err := tests.ExecuteHandler(ctx, h.Status, middleware.Config(c.Config))
+		return rec.Body.String(), err
+	}
+	status := func(id int64, withSession bool) error {
+		_, err := view(id, withSession, nil)
+		return err
+	}
+
+	assert.NoError(t, status(jobs[0].ID, true))
+
+	// Only admins can see the error the task failed with.
+	require.NoError(t, riveradapter.Register(c.Tasks, func(goctx.Context, tasks.ExampleTask) error {
+		return errors.New("dial tcp 10.0.0.5:5432: connection refused")
+	}))
+	require.Error(t, mem.Run(t.Context(), jobs[0].ID))
+
+	page, err := view(jobs[0].ID, true, nil)
+	require.NoError(t, err)
+	assert.Contains(t, page, "Something went wrong while processing the task.")
+	assert.NotContains(t, page, "10.0.0.5")
+
+	page, err = view(jobs[0].ID, true, &ent.User{Admin: true})
+	require.NoError(t, err)
+	assert.Contains(t, page, "dial tcp 10.0.0.5:5432: connection refused")
+
+	// Other sessions cannot view the task.
+	tests.AssertHTTPErrorCode(t, status(jobs[0].ID, false), http.StatusNotFound)
+
+	// Nor can jobs which were not submitted from the task page be viewed.
+	res, err := riveradapter.Insert(t.Context(), c.Tasks, tasks.ExampleTask{Message: "world"})
+	require.NoError(t, err)
+	tests.AssertHTTPErrorCode(t, status(res.ID, true), http.StatusNotFound)
+
+	tests.AssertHTTPErrorCode(t, status(res.ID+1, true), http.StatusNotFound)
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+)
+
+type ctxKey int
+
+const (
+	// loggerCtxKey is the key used to store a structured logger in a context.Context.
+	loggerCtxKey ctxKey = iota
+
+	// requestIDCtxKey is the key used to store the ID of the originating HTTP request in a context.Context.
+	requestIDCtxKey
+)
+
+// WithContext returns a copy of the context which stores the logger.
+// This is used where there is no Echo context, such as when processing tasks.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey, logger)
+}
+
+// FromContext returns the logger stored in the context, or provides the default logger if one is not present.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerCtxKey).(*slog.Logger); ok {
+		return l
+	}
+
+	return Default()
+}
+
+// WithRequestID returns a copy of the context which stores the ID of the HTTP request that it originated
+// from, so that work done on behalf of the request, such as tasks, can be correlated with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey, id)
+}
+
+// RequestID returns the ID of the HTTP request that the context originated from, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey).(string)
+	return id
+}
@@ -0,0 +1,25 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, Default(), FromContext(ctx))
+
+	logger := Default().With("a", "b")
+	ctx = WithContext(ctx, logger)
+	assert.Equal(t, logger, FromContext(ctx))
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, RequestID(ctx))
+
+	ctx = WithRequestID(ctx, "abc")
+	assert.Equal(t, "abc", RequestID(ctx))
+}
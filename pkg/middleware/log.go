@@ -23,6 +23,13 @@ func SetLogger() echo.MiddlewareFunc {
 
 			// TODO include other fields you may want in all logs for this request
 			log.Set(ctx, logger)
+
+			// Store the logger and request ID in the request context too, so that they are available to
+			// code without access to the Echo context, and are carried through to tasks that are inserted.
+			req := ctx.Request()
+			reqCtx := log.WithRequestID(log.WithContext(req.Context(), logger), rID)
+			ctx.SetRequest(req.WithContext(reqCtx))
+
 			return next(ctx)
 		}
 	}
@@ -70,6 +70,8 @@ func TestLogRequestID(t *testing.T) {
 	log.Ctx(ctx).Info("test")
 	rID := ctx.Response().Header().Get(echo.HeaderXRequestID)
 	assert.Equal(t, rID, h.GetAttr("request_id"))
+	assert.Equal(t, rID, log.RequestID(ctx.Request().Context()))
+	assert.Equal(t, log.Ctx(ctx), log.FromContext(ctx.Request().Context()))
 }
 
 func TestLogRequest(t *testing.T) {
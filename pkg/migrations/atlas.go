@@ -0,0 +1,293 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	"ariga.io/atlas/sql/postgres"
+	entmigrate "github.com/edkadigital/startmeup/ent/migrate"
+	"github.com/edkadigital/startmeup/pkg/log"
+)
+
+// atlasRevisionsTable is where Atlas records the applied versions of a migration directory
+const atlasRevisionsTable = "atlas_schema_revisions.atlas_schema_revisions"
+
+// AtlasManager applies and reports on a versioned Atlas migration directory, such as the one Ent generates.
+// Migrations are applied in process with the same semantics as `atlas migrate apply`, each file in its
+// own transaction, and revisions are recorded in the same table as the atlas CLI.
+type AtlasManager struct {
+	db          *sql.DB
+	dir         fs.FS
+	lockTimeout time.Duration
+}
+
+// NewAtlasManager creates a manager for the Atlas migration directory at the root of the given file system
+func NewAtlasManager(db *sql.DB, dir fs.FS) *AtlasManager {
+	return &AtlasManager{
+		db:          db,
+		dir:         dir,
+		lockTimeout: defaultLockTimeout,
+	}
+}
+
+// NewEntManager creates an Atlas migrations manager for the Ent schema migrations embedded in the binary
+func NewEntManager(db *sql.DB) *AtlasManager {
+	return NewAtlasManager(db, entmigrate.Migrations())
+}
+
+// WithLockTimeout sets how long a run waits for the migration lock held by another process.
+// A timeout of zero or less waits until the context is done.
+func (m *AtlasManager) WithLockTimeout(timeout time.Duration) *AtlasManager {
+	m.lockTimeout = timeout
+	return m
+}
+
+// Apply applies the pending migrations, after validating the directory against its atlas.sum file
+func (m *AtlasManager) Apply(ctx context.Context) error {
+	return withLock(ctx, m.db, atlasRevisionsTable, m.lockTimeout, func() error {
+		dir, err := m.migrateDir()
+		if err != nil {
+			return err
+		}
+
+		revisions := &atlasRevisions{db: m.db}
+		if err := revisions.ensureTable(ctx); err != nil {
+			return err
+		}
+
+		drv, err := postgres.Open(m.db)
+		if err != nil {
+			return fmt.Errorf("failed to open atlas driver: %w", err)
+		}
+
+		ex, err := migrate.NewExecutor(drv, dir, revisions)
+		if err != nil {
+			return fmt.Errorf("failed to create migration executor: %w", err)
+		}
+
+		pending, err := ex.Pending(ctx)
+		switch {
+		case errors.Is(err, migrate.ErrNoPendingFiles):
+			log.Default().Info("no pending schema migrations")
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to get pending migrations: %w", err)
+		}
+
+		revs, err := revisions.ReadRevisions(ctx)
+		if err != nil {
+			return err
+		}
+		logger := atlasLogger{}
+		migrate.LogIntro(logger, revs, pending)
+
+		for _, file := range pending {
+			if err := m.applyFile(ctx, dir, file, logger); err != nil {
+				return err
+			}
+		}
+
+		logger.Log(migrate.LogDone{})
+		return nil
+	})
+}
+
+// applyFile executes a migration file and records its revision in a single transaction
+func (m *AtlasManager) applyFile(ctx context.Context, dir migrate.Dir, file migrate.File, logger migrate.Logger) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			if err == nil {
+				err = fmt.Errorf("failed to rollback transaction: %w", rollbackErr)
+			} else {
+				log.Default().Warn("rollback error suppressed", "error", rollbackErr)
+			}
+		}
+	}()
+
+	drv, err := postgres.Open(tx)
+	if err != nil {
+		return fmt.Errorf("failed to open atlas driver: %w", err)
+	}
+
+	ex, err := migrate.NewExecutor(drv, dir, &atlasRevisions{db: tx}, migrate.WithLogger(logger))
+	if err != nil {
+		return fmt.Errorf("failed to create migration executor: %w", err)
+	}
+
+	if err := ex.Execute(ctx, file); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", file.Name(), err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// migrateDir copies the migration directory, including its atlas.sum file, into an Atlas directory
+func (m *AtlasManager) migrateDir() (migrate.Dir, error) {
+	dir := &migrate.MemDir{}
+	err := fs.WalkDir(m.dir, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		content, err := fs.ReadFile(m.dir, path)
+		if err != nil {
+			return err
+		}
+		return dir.WriteFile(path, content)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	return dir, nil
+}
+
+// Status returns every available migration in the order Atlas applies them, which is the order of their file
+// names, followed by any applied migrations which are no longer available. Migrations which Atlas only
+// partially applied are reported as pending.
+func (m *AtlasManager) Status(ctx context.Context) ([]Status, error) {
+	revisions, err := m.revisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(m.dir, "*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
+	}
+
+	available := make(map[string]bool, len(files))
+	statuses := make([]Status, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".sql")
+		version := versionPrefix(name)
+		available[version] = true
+		statuses = append(statuses, Status{Name: name, AppliedAt: revisions[version].executedAt})
+	}
+
+	var missing []Status
+	for version, rev := range revisions {
+		if !available[version] && !rev.executedAt.IsZero() {
+			missing = append(missing, Status{Name: rev.name(version), AppliedAt: rev.executedAt, Missing: true})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Name < missing[j].Name
+	})
+
+	return append(statuses, missing...), nil
+}
+
+// Plan returns the pending migrations in the order they would be applied, without executing them
+func (m *AtlasManager) Plan(ctx context.Context) ([]PlannedMigration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []PlannedMigration
+	for _, s := range statuses {
+		if !s.Pending() {
+			continue
+		}
+
+		content, err := fs.ReadFile(m.dir, s.Name+".sql")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file: %w", err)
+		}
+		plan = append(plan, PlannedMigration{Name: s.Name, SQL: string(content)})
+	}
+
+	return plan, nil
+}
+
+// atlasRevision is an applied version recorded by Atlas
+type atlasRevision struct {
+	description string
+
+	// executedAt is zero when the version was not fully applied
+	executedAt time.Time
+}
+
+func (r atlasRevision) name(version string) string {
+	if r.description == "" {
+		return version
+	}
+	return version + "_" + r.description
+}
+
+// revisions returns the versions recorded in the Atlas revisions table, or nothing if it does not exist yet
+func (m *AtlasManager) revisions(ctx context.Context) (revisions map[string]atlasRevision, err error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", atlasRevisionsTable).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check for revisions table: %w", err)
+	}
+
+	revisions = make(map[string]atlasRevision)
+	if !exists {
+		return revisions, nil
+	}
+
+	query := fmt.Sprintf("SELECT version, description, executed_at, applied >= total FROM %s", atlasRevisionsTable)
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close rows: %w", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			version  string
+			rev      atlasRevision
+			executed time.Time
+			complete bool
+		)
+		if err := rows.Scan(&version, &rev.description, &executed, &complete); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		if complete {
+			rev.executedAt = executed
+		}
+		revisions[version] = rev
+	}
+
+	return revisions, rows.Err()
+}
+
+// atlasLogger reports the progress of Atlas migrations through the application logger
+type atlasLogger struct{}
+
+func (atlasLogger) Log(entry migrate.LogEntry) {
+	switch e := entry.(type) {
+	case migrate.LogExecution:
+		log.Default().Info("applying schema migrations", "from", e.From, "to", e.To, "files", len(e.Files))
+	case migrate.LogFile:
+		log.Default().Info("applying schema migration", "version", e.File.Version(), "description", e.File.Desc())
+	case migrate.LogStmt:
+		log.Default().Debug("executing schema migration statement", "sql", e.SQL)
+	case migrate.LogError:
+		log.Default().Error("schema migration failed", "error", e.Error, "sql", e.SQL)
+	case migrate.LogDone:
+		log.Default().Info("schema migrations completed")
+	}
+}
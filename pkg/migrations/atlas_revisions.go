@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+	"ariga.io/atlas/sql/schema"
+)
+
+// atlasRevisions stores applied revisions in the same table, and with the same columns, as the atlas CLI.
+// Databases migrated by the CLI can therefore be migrated in process, and the other way around.
+type atlasRevisions struct {
+	db schema.ExecQuerier
+}
+
+var _ migrate.RevisionReadWriter = (*atlasRevisions)(nil)
+
+// ensureTable creates the revisions table if it does not exist yet
+func (r *atlasRevisions) ensureTable(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE SCHEMA IF NOT EXISTS atlas_schema_revisions;
+		CREATE TABLE IF NOT EXISTS %s (
+			version character varying NOT NULL,
+			description character varying NOT NULL,
+			type bigint NOT NULL DEFAULT 2,
+			applied bigint NOT NULL DEFAULT 0,
+			total bigint NOT NULL DEFAULT 0,
+			executed_at timestamptz NOT NULL,
+			execution_time bigint NOT NULL,
+			error text NULL,
+			error_stmt text NULL,
+			hash character varying NOT NULL,
+			partial_hashes jsonb NULL,
+			operator_version character varying NOT NULL,
+			PRIMARY KEY (version)
+		);
+	`, atlasRevisionsTable))
+	if err != nil {
+		return fmt.Errorf("failed to create revisions table: %w", err)
+	}
+
+	return nil
+}
+
+// Ident returns the revisions table, which is excluded when checking the database is clean
+func (r *atlasRevisions) Ident() *migrate.TableIdent {
+	return &migrate.TableIdent{Schema: "atlas_schema_revisions", Name: "atlas_schema_revisions"}
+}
+
+// ReadRevisions returns all revisions ordered by version
+func (r *atlasRevisions) ReadRevisions(ctx context.Context) (revs []*migrate.Revision, err error) {
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT version, description, type, applied, total, executed_at, execution_time,
+			COALESCE(error, ''), COALESCE(error_stmt, ''), hash, partial_hashes, operator_version
+		FROM %s
+		ORDER BY version
+	`, atlasRevisionsTable))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close rows: %w", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			rev           migrate.Revision
+			executionTime int64
+			partialHashes []byte
+		)
+		err := rows.Scan(
+			&rev.Version,
+			&rev.Description,
+			&rev.Type,
+			&rev.Applied,
+			&rev.Total,
+			&rev.ExecutedAt,
+			&executionTime,
+			&rev.Error,
+			&rev.ErrorStmt,
+			&rev.Hash,
+			&partialHashes,
+			&rev.OperatorVersion,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+
+		rev.ExecutionTime = time.Duration(executionTime)
+		if len(partialHashes) > 0 {
+			if err := json.Unmarshal(partialHashes, &rev.PartialHashes); err != nil {
+				return nil, fmt.Errorf("failed to decode partial hashes of revision %s: %w", rev.Version, err)
+			}
+		}
+		revs = append(revs, &rev)
+	}
+
+	return revs, rows.Err()
+}
+
+// ReadRevision returns the revision of the given version, or migrate.ErrRevisionNotExist
+func (r *atlasRevisions) ReadRevision(ctx context.Context, version string) (*migrate.Revision, error) {
+	revs, err := r.ReadRevisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rev := range revs {
+		if rev.Version == version {
+			return rev, nil
+		}
+	}
+
+	return nil, migrate.ErrRevisionNotExist
+}
+
+// WriteRevision inserts or updates a revision
+func (r *atlasRevisions) WriteRevision(ctx context.Context, rev *migrate.Revision) error {
+	var partialHashes any
+	if rev.PartialHashes != nil {
+		b, err := json.Marshal(rev.PartialHashes)
+		if err != nil {
+			return fmt.Errorf("failed to encode partial hashes: %w", err)
+		}
+		partialHashes = string(b)
+	}
+
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (version, description, type, applied, total, executed_at, execution_time,
+			error, error_stmt, hash, partial_hashes, operator_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), $10, $11, $12)
+		ON CONFLICT (version) DO UPDATE SET
+			type = EXCLUDED.type,
+			applied = EXCLUDED.applied,
+			total = EXCLUDED.total,
+			execution_time = EXCLUDED.execution_time,
+			error = EXCLUDED.error,
+			error_stmt = EXCLUDED.error_stmt,
+			hash = EXCLUDED.hash,
+			partial_hashes = EXCLUDED.partial_hashes,
+			operator_version = EXCLUDED.operator_version
+	`, atlasRevisionsTable),
+		rev.Version,
+		rev.Description,
+		int64(rev.Type),
+		rev.Applied,
+		rev.Total,
+		rev.ExecutedAt,
+		int64(rev.ExecutionTime),
+		rev.Error,
+		rev.ErrorStmt,
+		rev.Hash,
+		partialHashes,
+		rev.OperatorVersion,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write revision: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRevision deletes the revision of the given version
+func (r *atlasRevisions) DeleteRevision(ctx context.Context, version string) error {
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version = $1", atlasRevisionsTable), version)
+	if err != nil {
+		return fmt.Errorf("failed to delete revision: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"ariga.io/atlas/sql/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtlasManager_MigrateDir(t *testing.T) {
+	dir, err := NewEntManager(nil).migrateDir()
+	require.NoError(t, err)
+	require.NoError(t, migrate.Validate(dir))
+
+	files, err := dir.Files()
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	assert.Equal(t, "20250426174645", files[0].Version())
+
+	// Files which don't match atlas.sum are rejected before anything is applied.
+	dir, err = NewAtlasManager(nil, fstest.MapFS{
+		"20250101000000_edited.sql": {Data: []byte("SELECT 1;")},
+		"atlas.sum":                 {Data: []byte("h1:invalid=\n20250101000000_edited.sql h1:invalid=\n")},
+	}).migrateDir()
+	require.NoError(t, err)
+	assert.Error(t, migrate.Validate(dir))
+}
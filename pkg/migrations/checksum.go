@@ -0,0 +1,230 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+)
+
+// DriftKind describes how an applied migration differs from its file
+type DriftKind string
+
+const (
+	// DriftChanged means the migration file was edited after it was applied
+	DriftChanged DriftKind = "changed"
+
+	// DriftMissing means the migration file no longer exists
+	DriftMissing DriftKind = "missing"
+
+	// DriftUnverified means no checksum was recorded when the migration was applied, so it cannot be
+	// compared with its file
+	DriftUnverified DriftKind = "unverified"
+)
+
+// Drift describes an applied migration which no longer matches its file
+type Drift struct {
+	Name    string
+	Kind    DriftKind
+	Applied string
+	Current string
+}
+
+// DriftError is returned when applied migrations no longer match their files
+type DriftError struct {
+	Drift []Drift
+}
+
+func (e *DriftError) Error() string {
+	var b strings.Builder
+	b.WriteString("applied migrations do not match their files:")
+	for _, d := range e.Drift {
+		switch d.Kind {
+		case DriftChanged:
+			fmt.Fprintf(&b, "\n  %s: changed (applied %s, file %s)", d.Name, d.Applied, d.Current)
+		case DriftUnverified:
+			fmt.Fprintf(&b, "\n  %s: unverified (no checksum was recorded)", d.Name)
+		default:
+			fmt.Fprintf(&b, "\n  %s: %s", d.Name, d.Kind)
+		}
+	}
+	return b.String()
+}
+
+// Verify compares the checksum recorded for each applied migration with its file, returning a
+// DriftError if any were changed, are missing or have no checksum to compare. Go migrations have no
+// file and are skipped.
+func (m *Manager) Verify(ctx context.Context) error {
+	applied, err := m.appliedChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	drift, err := m.drift(applied)
+	if err != nil {
+		return err
+	}
+
+	if len(drift) > 0 {
+		return &DriftError{Drift: drift}
+	}
+
+	return nil
+}
+
+// drift returns how the applied migrations differ from their files
+func (m *Manager) drift(applied []appliedChecksum) ([]Drift, error) {
+	var drift []Drift
+	for _, a := range applied {
+		if _, ok := m.goMigrations[a.name]; ok {
+			continue
+		}
+
+		content, err := fs.ReadFile(m.migrations, m.upFile(a.name))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			drift = append(drift, Drift{Name: a.name, Kind: DriftMissing, Applied: a.checksum})
+		case err != nil:
+			return nil, fmt.Errorf("failed to read migration file: %w", err)
+		case a.checksum == "":
+			drift = append(drift, Drift{Name: a.name, Kind: DriftUnverified, Current: checksum(content)})
+		case checksum(content) != a.checksum:
+			drift = append(drift, Drift{
+				Name:    a.name,
+				Kind:    DriftChanged,
+				Applied: a.checksum,
+				Current: checksum(content),
+			})
+		}
+	}
+
+	return drift, nil
+}
+
+// backfillChecksums records the checksum of the current file for each applied migration which has
+// none, once the checksum column is added to a table created before checksums were recorded. Those
+// whose files are missing are left without one, and reported by Verify.
+func (m *Manager) backfillChecksums(ctx context.Context) error {
+	applied, err := m.queryChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET checksum = $2 WHERE migration_name = $1 AND checksum IS NULL", m.migrationsTable)
+	for _, a := range applied {
+		if _, ok := m.goMigrations[a.name]; ok || a.checksum != "" {
+			continue
+		}
+
+		content, err := fs.ReadFile(m.migrations, m.upFile(a.name))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("Migration %s was applied without a checksum and its file is missing", a.name)
+			continue
+		case err != nil:
+			return fmt.Errorf("failed to read migration file: %w", err)
+		}
+
+		if _, err := m.db.ExecContext(ctx, query, a.name, checksum(content)); err != nil {
+			return fmt.Errorf("failed to record migration checksum: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Repair re-baselines the applied migrations against the current files. Recorded checksums are
+// replaced by those of the files, and records of migrations whose files are missing are removed.
+func (m *Manager) Repair(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		applied, err := m.appliedChecksums(ctx)
+		if err != nil {
+			return err
+		}
+
+		updateQuery := fmt.Sprintf("UPDATE %s SET checksum = $2 WHERE migration_name = $1", m.migrationsTable)
+		deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE migration_name = $1", m.migrationsTable)
+
+		for _, a := range applied {
+			// Go migrations have no file to checksum
+			if _, ok := m.goMigrations[a.name]; ok {
+				continue
+			}
+
+			content, err := fs.ReadFile(m.migrations, m.upFile(a.name))
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				log.Printf("Removing record of missing migration: %s", a.name)
+				if _, err := m.db.ExecContext(ctx, deleteQuery, a.name); err != nil {
+					return fmt.Errorf("failed to remove migration record: %w", err)
+				}
+			case err != nil:
+				return fmt.Errorf("failed to read migration file: %w", err)
+			case checksum(content) != a.checksum:
+				log.Printf("Updating checksum of migration: %s", a.name)
+				if _, err := m.db.ExecContext(ctx, updateQuery, a.name, checksum(content)); err != nil {
+					return fmt.Errorf("failed to update migration checksum: %w", err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// appliedChecksum is the checksum recorded for an applied migration
+type appliedChecksum struct {
+	name     string
+	checksum string
+}
+
+// appliedChecksums returns the checksum recorded for each distinct applied migration, which is
+// empty if none was recorded
+func (m *Manager) appliedChecksums(ctx context.Context) ([]appliedChecksum, error) {
+	// Make sure the migrations table exists
+	if err := m.EnsureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.queryChecksums(ctx)
+}
+
+// queryChecksums queries the checksum recorded for each distinct applied migration
+func (m *Manager) queryChecksums(ctx context.Context) (applied []appliedChecksum, err error) {
+	query := fmt.Sprintf(`
+		SELECT migration_name, COALESCE(MAX(checksum), '')
+		FROM %s
+		GROUP BY migration_name
+		ORDER BY MIN(id)
+	`, m.migrationsTable)
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close rows: %w", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var a appliedChecksum
+		if err := rows.Scan(&a.name, &a.checksum); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		applied = append(applied, a)
+	}
+
+	return applied, rows.Err()
+}
+
+// checksum returns the hex encoded SHA-256 checksum of a migration file
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
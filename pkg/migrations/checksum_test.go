@@ -0,0 +1,138 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/edkadigital/startmeup/ent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", checksum(nil))
+	assert.NotEqual(t, checksum([]byte("SELECT 1;")), checksum([]byte("SELECT 2;")))
+}
+
+func TestDriftError(t *testing.T) {
+	err := &DriftError{Drift: []Drift{
+		{Name: "001_first", Kind: DriftChanged, Applied: "aaa", Current: "bbb"},
+		{Name: "002_second", Kind: DriftMissing, Applied: "ccc"},
+		{Name: "003_third", Kind: DriftUnverified, Current: "ddd"},
+	}}
+	assert.Equal(t, "applied migrations do not match their files:\n"+
+		"  001_first: changed (applied aaa, file bbb)\n"+
+		"  002_second: missing\n"+
+		"  003_third: unverified (no checksum was recorded)", err.Error())
+}
+
+func TestManager_Drift(t *testing.T) {
+	m := newTestManager("001_first.sql", "002_second.up.sql", "002_second.down.sql", "003_third.sql")
+	require.NoError(t, m.Register("004_backfill", func(context.Context, *sql.Tx, *ent.Client) error {
+		return nil
+	}, nil))
+	current := checksum([]byte("SELECT 1;"))
+
+	drift, err := m.drift([]appliedChecksum{
+		{name: "001_first", checksum: current},
+		{name: "002_second", checksum: "aaa"},
+		{name: "003_third"},
+		{name: "004_backfill"},
+		{name: "005_removed", checksum: "bbb"},
+		{name: "006_removed_unverified"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Drift{
+		{Name: "002_second", Kind: DriftChanged, Applied: "aaa", Current: current},
+		{Name: "003_third", Kind: DriftUnverified, Current: current},
+		{Name: "005_removed", Kind: DriftMissing, Applied: "bbb"},
+		{Name: "006_removed_unverified", Kind: DriftMissing},
+	}, drift)
+}
+
+func TestManager_BackfillChecksums(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	fsys := fstest.MapFS{"001_first.sql": {Data: []byte("SELECT 1;")}}
+	m := newDBManager(t, db, fsys, "test_migrations_backfill")
+
+	// A table created before checksums were recorded, with a migration whose file was since removed
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE test_migrations_backfill (
+			id SERIAL PRIMARY KEY,
+			migration_name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		INSERT INTO test_migrations_backfill (migration_name) VALUES ('001_first'), ('002_removed');
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.EnsureMigrationsTable(ctx))
+	applied, err := m.queryChecksums(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []appliedChecksum{
+		{name: "001_first", checksum: checksum([]byte("SELECT 1;"))},
+		{name: "002_removed"},
+	}, applied)
+
+	// Checksums are only backfilled when the column is added
+	_, err = db.ExecContext(ctx, "UPDATE test_migrations_backfill SET checksum = NULL")
+	require.NoError(t, err)
+	require.NoError(t, m.EnsureMigrationsTable(ctx))
+	applied, err = m.queryChecksums(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []appliedChecksum{{name: "001_first"}, {name: "002_removed"}}, applied)
+}
+
+func TestManager_VerifyAndRepair(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	fsys := fstest.MapFS{
+		"001_first.sql":  {Data: []byte("SELECT 1;")},
+		"002_second.sql": {Data: []byte("SELECT 2;")},
+		"003_third.sql":  {Data: []byte("SELECT 3;")},
+	}
+	m := newDBManager(t, db, fsys, "test_migrations_verify")
+
+	require.NoError(t, m.ApplyPendingMigrations(ctx))
+	require.NoError(t, m.Verify(ctx))
+
+	// Edit one applied file and remove another
+	fsys["002_second.sql"] = &fstest.MapFile{Data: []byte("SELECT 22;")}
+	delete(fsys, "003_third.sql")
+
+	var driftErr *DriftError
+	require.ErrorAs(t, m.Verify(ctx), &driftErr)
+	assert.Equal(t, []Drift{
+		{
+			Name:    "002_second",
+			Kind:    DriftChanged,
+			Applied: checksum([]byte("SELECT 2;")),
+			Current: checksum([]byte("SELECT 22;")),
+		},
+		{Name: "003_third", Kind: DriftMissing, Applied: checksum([]byte("SELECT 3;"))},
+	}, driftErr.Drift)
+
+	require.NoError(t, m.Repair(ctx))
+	require.NoError(t, m.Verify(ctx))
+
+	applied, err := m.GetAppliedMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"001_first", "002_second"}, applied)
+}
+
+// newDBManager creates a migrations manager for the test database, dropping its migrations table before and after
+// the test
+func newDBManager(t *testing.T, db *sql.DB, fsys fstest.MapFS, table string) *Manager {
+	t.Helper()
+
+	drop := fmt.Sprintf("DROP TABLE IF EXISTS %s", table)
+	_, err := db.ExecContext(t.Context(), drop)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.ExecContext(context.Background(), drop) })
+
+	return NewManager(db, fsys, table)
+}
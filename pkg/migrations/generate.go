@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"ariga.io/atlas/sql/migrate"
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"entgo.io/ent/dialect/sql/schema"
+	entmigrate "github.com/edkadigital/startmeup/ent/migrate"
+	_ "github.com/jackc/pgx/v5/stdlib" // Import pgx driver
+)
+
+// EntMigrationsDir is the Ent migration directory, relative to the workspace root
+const EntMigrationsDir = "ent/migrate/migrations"
+
+// migrationNamePattern restricts generated migration names to those safe to use in file names
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// GenerateEntMigration diffs the generated Ent schema against the state of the migration directory, and writes
+// the changes to a new timestamped migration file in the directory, updating its atlas.sum file.
+// The state of the directory is computed by replaying it on the dev database, which must be an empty Postgres
+// database used only for this purpose, and is cleaned up afterwards.
+// It returns the name of the new file, or an empty string if the schema has not changed.
+func GenerateEntMigration(ctx context.Context, devURL, dirPath, name string) (string, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name %q: use lowercase letters, digits and underscores", name)
+	}
+
+	dir, err := migrate.NewLocalDir(dirPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open migration directory: %w", err)
+	}
+
+	before, err := migrationFileNames(dir)
+	if err != nil {
+		return "", err
+	}
+
+	db, err := sql.Open("pgx", devURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to open dev database: %w", err)
+	}
+	defer db.Close()
+
+	m, err := schema.NewMigrate(entsql.OpenDB(dialect.Postgres, db),
+		schema.WithDir(dir),
+		schema.WithMigrationMode(schema.ModeReplay),
+		schema.WithFormatter(migrate.DefaultFormatter),
+		schema.WithErrNoPlan(true),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create schema differ: %w", err)
+	}
+
+	if err := m.NamedDiff(ctx, name, entmigrate.Tables...); err != nil {
+		if errors.Is(err, migrate.ErrNoPlan) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to diff schema: %w", err)
+	}
+
+	after, err := migrationFileNames(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range after {
+		if !slices.Contains(before, file) {
+			return file, nil
+		}
+	}
+
+	return "", errors.New("no migration file was written")
+}
+
+// migrationFileNames returns the names of the files in a migration directory
+func migrationFileNames(dir migrate.Dir) ([]string, error) {
+	files, err := dir.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
+	}
+
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name()
+	}
+
+	return names, nil
+}
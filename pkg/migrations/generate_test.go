@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateEntMigration_Name(t *testing.T) {
+	for _, name := range []string{"", "Add Posts", "../posts", "add-posts"} {
+		_, err := GenerateEntMigration(context.Background(), "", t.TempDir(), name)
+		assert.ErrorContains(t, err, "invalid migration name", name)
+	}
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/edkadigital/startmeup/ent"
+)
+
+// MigrationFunc is a migration written in Go, for changes which need application logic such as backfilling
+// data through the ORM. It runs in the transaction which records the migration, and the ORM client is bound
+// to that transaction.
+type MigrationFunc func(ctx context.Context, tx *sql.Tx, orm *ent.Client) error
+
+// goMigration is a registered Go migration
+type goMigration struct {
+	up   MigrationFunc
+	down MigrationFunc
+}
+
+// Register adds a Go migration which is ordered by version alongside the migration files and tracked in the
+// same migrations table. The down function is optional, and without it the migration cannot be rolled back.
+func (m *Manager) Register(name string, up, down MigrationFunc) error {
+	if name == "" || up == nil {
+		return fmt.Errorf("go migration requires a name and an up function")
+	}
+
+	if _, err := migrationVersion(name); err != nil {
+		return err
+	}
+
+	if _, ok := m.goMigrations[name]; ok {
+		return fmt.Errorf("go migration %s is already registered", name)
+	}
+
+	for _, file := range []string{name + ".sql", name + upSuffix} {
+		if _, err := fs.Stat(m.migrations, file); err == nil {
+			return fmt.Errorf("go migration %s conflicts with migration file %s", name, file)
+		}
+	}
+
+	m.goMigrations[name] = goMigration{up: up, down: down}
+	return nil
+}
+
+// newORM returns an ORM client which runs every query in the given transaction
+func newORM(tx *sql.Tx) *ent.Client {
+	return ent.NewClient(ent.Driver(entsql.NewDriver(dialect.Postgres, entsql.Conn{ExecQuerier: tx})))
+}
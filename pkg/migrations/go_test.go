@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/edkadigital/startmeup/ent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Register(t *testing.T) {
+	m := newTestManager("001_first.sql", "003_third.up.sql", "003_third.down.sql")
+	noop := func(ctx context.Context, tx *sql.Tx, orm *ent.Client) error {
+		return nil
+	}
+
+	require.NoError(t, m.Register("002_backfill", noop, nil))
+	require.NoError(t, m.Register("004_reversible", noop, noop))
+
+	assert.Error(t, m.Register("002_backfill", noop, nil))
+	assert.Error(t, m.Register("001_first", noop, nil))
+	assert.Error(t, m.Register("003_third", noop, nil))
+	assert.Error(t, m.Register("005_no_up", nil, noop))
+	assert.Error(t, m.Register("", noop, nil))
+	assert.Error(t, m.Register("backfill", noop, nil))
+
+	// Go migrations are ordered alongside the files.
+	names, err := m.migrationNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"001_first", "002_backfill", "003_third", "004_reversible"}, names)
+
+	// Go migrations without a down function cannot be rolled back.
+	ctx := context.Background()
+	err = m.rollbackMigrations(ctx, []string{"004_reversible", "003_third", "002_backfill"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "002_backfill cannot be rolled back")
+}
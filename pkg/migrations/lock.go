@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// defaultLockTimeout is how long a run waits for the migration lock unless configured otherwise
+	defaultLockTimeout = 5 * time.Minute
+
+	// lockPollInterval is how often the migration lock is tried while waiting for it
+	lockPollInterval = time.Second
+
+	// lockLogInterval is how often a run logs that it is still waiting for the migration lock
+	lockLogInterval = 10 * time.Second
+)
+
+// ErrLockTimeout is returned when the migration lock could not be acquired within the lock timeout
+var ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+
+// WithLockTimeout sets how long a run waits for the migration lock held by another process.
+// A timeout of zero or less waits until the context is done.
+func (m *Manager) WithLockTimeout(timeout time.Duration) *Manager {
+	m.lockTimeout = timeout
+	return m
+}
+
+// withLock runs fn while holding the advisory lock keyed on the migrations table
+func (m *Manager) withLock(ctx context.Context, fn func() error) error {
+	return withLock(ctx, m.db, m.migrationsTable, m.lockTimeout, fn)
+}
+
+// withLock runs fn while holding a session level advisory lock on the given key, waiting up to the timeout
+// for it to be released by another process. The lock is held by a dedicated connection, while migrations
+// run on the pool as usual.
+func withLock(ctx context.Context, db *sql.DB, key string, timeout time.Duration, fn func() error) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+	defer func() {
+		if closeErr := conn.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close migration lock connection: %w", closeErr)
+		}
+	}()
+
+	start := time.Now()
+	var logged time.Time
+	for {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", key).Scan(&locked)
+		if err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		waited := time.Since(start)
+		if timeout > 0 && waited >= timeout {
+			return fmt.Errorf("%w on %s after %s", ErrLockTimeout, key, timeout)
+		}
+
+		if time.Since(logged) >= lockLogInterval {
+			log.Printf("Waiting for migration lock on %s held by another process (waited %s)",
+				key, waited.Round(time.Second))
+			logged = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to acquire migration lock: %w", ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	if !logged.IsZero() {
+		log.Printf("Acquired migration lock on %s", key)
+	}
+
+	defer func() {
+		// Release the lock even if the context was cancelled, since the connection returns to the pool
+		_, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", key)
+		if unlockErr != nil {
+			if err == nil {
+				err = fmt.Errorf("failed to release migration lock: %w", unlockErr)
+			} else {
+				log.Printf("migration lock release error suppressed: %v", unlockErr)
+			}
+		}
+	}()
+
+	return fn()
+}
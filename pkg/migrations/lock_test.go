@@ -0,0 +1,142 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLock_Blocks(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	key := "test_lock_blocks"
+
+	acquired := make(chan struct{})
+	release := make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		first <- withLock(ctx, db, key, 0, func() error {
+			close(acquired)
+			<-release
+			return nil
+		})
+	}()
+	<-acquired
+
+	var ran bool
+	second := make(chan error, 1)
+	go func() {
+		second <- withLock(ctx, db, key, 0, func() error {
+			ran = true
+			return nil
+		})
+	}()
+
+	// The second caller waits for as long as the lock is held
+	select {
+	case err := <-second:
+		t.Fatalf("second caller did not wait for the lock: %v", err)
+	case <-time.After(2 * lockPollInterval):
+	}
+
+	close(release)
+	require.NoError(t, <-first)
+	require.NoError(t, <-second)
+	assert.True(t, ran)
+}
+
+func TestWithLock_Timeout(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	key := "test_lock_timeout"
+
+	acquired := make(chan struct{})
+	release := make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		first <- withLock(ctx, db, key, 0, func() error {
+			close(acquired)
+			<-release
+			return nil
+		})
+	}()
+	<-acquired
+
+	err := withLock(ctx, db, key, 100*time.Millisecond, func() error {
+		t.Error("fn ran without holding the lock")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrLockTimeout)
+
+	close(release)
+	require.NoError(t, <-first)
+}
+
+func TestWithLock_Releases(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	key := "test_lock_releases"
+
+	require.NoError(t, withLock(ctx, db, key, 0, func() error {
+		assert.False(t, tryLock(t, db, key), "lock is not held while fn runs")
+		return nil
+	}))
+	assert.True(t, tryLock(t, db, key), "lock is not released after fn returns")
+
+	failed := errors.New("migration failed")
+	err := withLock(ctx, db, key, 0, func() error {
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.True(t, tryLock(t, db, key), "lock is not released after fn errors")
+}
+
+// tryLock returns true if the advisory lock on the key is free, by acquiring and releasing it on another
+// connection
+func tryLock(t *testing.T, db *sql.DB, key string) bool {
+	t.Helper()
+
+	conn, err := db.Conn(t.Context())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	var locked bool
+	require.NoError(t, conn.QueryRowContext(t.Context(), "SELECT pg_try_advisory_lock(hashtext($1))", key).Scan(&locked))
+	if locked {
+		_, err := conn.ExecContext(t.Context(), "SELECT pg_advisory_unlock(hashtext($1))", key)
+		require.NoError(t, err)
+	}
+	return locked
+}
+
+// openTestDB connects to the database set by DB_TEST_CONNECTION, or DATABASE_URL as with the config, skipping
+// the test if it is not available.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	connection := os.Getenv("DB_TEST_CONNECTION")
+	if connection == "" {
+		connection = os.Getenv("DATABASE_URL")
+	}
+	if connection == "" {
+		t.Skip("neither DB_TEST_CONNECTION nor DATABASE_URL is set")
+	}
+
+	db, err := sql.Open("pgx", connection)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		t.Skipf("test database is not available: %v", err)
+	}
+
+	return db
+}
@@ -4,52 +4,90 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
 	"log"
-	"os"
-	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	migrationfiles "github.com/edkadigital/startmeup/migrations"
 )
 
-// Manager handles database migrations
+const (
+	// upSuffix is the file suffix of an up migration which has a paired down migration
+	upSuffix = ".up.sql"
+
+	// downSuffix is the file suffix of a down migration
+	downSuffix = ".down.sql"
+)
+
+// Manager handles database migrations.
+// Runs which apply or revert migrations hold a Postgres advisory lock, so concurrent runs against the
+// same migrations table wait for each other rather than applying the same file twice.
 type Manager struct {
 	db              *sql.DB
-	migrationsDir   string
+	migrations      fs.FS
 	migrationsTable string
+	lockTimeout     time.Duration
+	goMigrations    map[string]goMigration
 }
 
-// NewManager creates a new migrations manager
-func NewManager(db *sql.DB, migrationsDir, migrationsTable string) *Manager {
+// NewManager creates a new migrations manager which reads migration files from the root of the given file system
+func NewManager(db *sql.DB, migrations fs.FS, migrationsTable string) *Manager {
 	return &Manager{
 		db:              db,
-		migrationsDir:   migrationsDir,
+		migrations:      migrations,
 		migrationsTable: migrationsTable,
+		lockTimeout:     defaultLockTimeout,
+		goMigrations:    make(map[string]goMigration),
 	}
 }
 
-// NewRiverManager creates a migrations manager specifically for River
+// NewRiverManager creates a migrations manager specifically for River, using the migrations embedded in the binary
 func NewRiverManager(db *sql.DB) *Manager {
-	return NewManager(db, "migrations/river", "river_migrations")
+	return NewManager(db, migrationfiles.River(), "river_migrations")
 }
 
-// EnsureMigrationsTable ensures the migrations table exists
+// EnsureMigrationsTable ensures the migrations table exists. The checksums of migrations applied
+// before they were recorded are backfilled when the checksum column is added.
 func (m *Manager) EnsureMigrationsTable(ctx context.Context) error {
-	query := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id SERIAL PRIMARY KEY,
-			migration_name TEXT NOT NULL,
-			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		);
-	`, m.migrationsTable)
-
-	_, err := m.db.ExecContext(ctx, query)
+	var addsChecksum bool
+	err := m.db.QueryRowContext(ctx, `
+		SELECT to_regclass($1) IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM pg_attribute
+			WHERE attrelid = to_regclass($1) AND attname = 'checksum' AND NOT attisdropped
+		)
+	`, m.migrationsTable).Scan(&addsChecksum)
 	if err != nil {
+		return fmt.Errorf("failed to inspect migrations table: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, m.tableQuery()); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
+	if addsChecksum {
+		return m.backfillChecksums(ctx)
+	}
+
 	return nil
 }
 
+// tableQuery returns the query which creates the migrations table, or adds the checksum column to
+// tables created before checksums were recorded
+func (m *Manager) tableQuery() string {
+	return fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %[1]s (
+			id SERIAL PRIMARY KEY,
+			migration_name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS checksum TEXT;
+	`, m.migrationsTable)
+}
+
 // GetAppliedMigrations returns a list of already applied migrations
 func (m *Manager) GetAppliedMigrations(ctx context.Context) ([]string, error) {
 	// Make sure the migrations table exists
@@ -97,35 +135,33 @@ func (m *Manager) GetPendingMigrations(ctx context.Context) ([]string, error) {
 		appliedMap[name] = true
 	}
 
-	// Get available migration files
-	files, err := filepath.Glob(filepath.Join(m.migrationsDir, "*.sql"))
+	// Get available migrations
+	names, err := m.migrationNames()
 	if err != nil {
-		return nil, fmt.Errorf("failed to list migration files: %w", err)
+		return nil, err
 	}
 
 	// Collect pending migrations
 	var pending []string
-	for _, file := range files {
-		name := filepath.Base(file)
-		name = strings.TrimSuffix(name, ".sql")
+	for _, name := range names {
 		if !appliedMap[name] {
 			pending = append(pending, name)
 		}
 	}
 
-	// Sort migrations by name
-	sort.Strings(pending)
-
 	return pending, nil
 }
 
 // ApplyMigration applies a single migration
-func (m *Manager) ApplyMigration(ctx context.Context, name string) error {
-	// Read migration file
-	filePath := filepath.Join(m.migrationsDir, name+".sql")
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
+func (m *Manager) ApplyMigration(ctx context.Context, name string) (err error) {
+	// Read migration file, unless it is a Go migration
+	gm, isGo := m.goMigrations[name]
+	var content []byte
+	if !isGo {
+		content, err = fs.ReadFile(m.migrations, m.upFile(name))
+		if err != nil {
+			return fmt.Errorf("failed to read migration file: %w", err)
+		}
 	}
 
 	// Start a transaction
@@ -143,27 +179,27 @@ func (m *Manager) ApplyMigration(ctx context.Context, name string) error {
 		}
 	}()
 
-	// Execute migration SQL
-	if _, err := tx.ExecContext(ctx, string(content)); err != nil {
+	// Execute migration SQL, or run the Go migration
+	if isGo {
+		if err := gm.up(ctx, tx, newORM(tx)); err != nil {
+			return fmt.Errorf("failed to run migration: %w", err)
+		}
+	} else if _, err := tx.ExecContext(ctx, string(content)); err != nil {
 		return fmt.Errorf("failed to execute migration: %w", err)
 	}
 
 	// Make sure the migrations table wasn't dropped by the migration
-	createTableQuery := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id SERIAL PRIMARY KEY,
-			migration_name TEXT NOT NULL,
-			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-		);
-	`, m.migrationsTable)
-
-	if _, err := tx.ExecContext(ctx, createTableQuery); err != nil {
+	if _, err := tx.ExecContext(ctx, m.tableQuery()); err != nil {
 		return fmt.Errorf("failed to ensure migrations table exists: %w", err)
 	}
 
 	// Record the migration
-	insertQuery := fmt.Sprintf("INSERT INTO %s (migration_name) VALUES ($1)", m.migrationsTable)
-	if _, err := tx.ExecContext(ctx, insertQuery, name); err != nil {
+	insertQuery := fmt.Sprintf("INSERT INTO %s (migration_name, checksum) VALUES ($1, $2)", m.migrationsTable)
+	var sum sql.NullString
+	if !isGo {
+		sum = sql.NullString{String: checksum(content), Valid: true}
+	}
+	if _, err := tx.ExecContext(ctx, insertQuery, name, sum); err != nil {
 		return fmt.Errorf("failed to record migration: %w", err)
 	}
 
@@ -177,51 +213,283 @@ func (m *Manager) ApplyMigration(ctx context.Context, name string) error {
 
 // ApplyPendingMigrations applies all pending migrations
 func (m *Manager) ApplyPendingMigrations(ctx context.Context) error {
-	// Get pending migrations
-	pending, err := m.GetPendingMigrations(ctx)
+	return m.withLock(ctx, func() error {
+		// Get pending migrations
+		pending, err := m.GetPendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Apply each migration
+		for _, name := range pending {
+			log.Printf("Applying migration: %s", name)
+			if err := m.ApplyMigration(ctx, name); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", name, err)
+			}
+			log.Printf("Migration applied: %s", name)
+		}
+
+		return nil
+	})
+}
+
+// RollbackMigration reverts a single applied migration using its down file or Go down function
+func (m *Manager) RollbackMigration(ctx context.Context, name string) (err error) {
+	// Read down migration file, unless it is a Go migration
+	gm, isGo := m.goMigrations[name]
+	var content []byte
+	switch {
+	case isGo && gm.down == nil:
+		return fmt.Errorf("go migration %s has no down function", name)
+	case !isGo:
+		content, err = fs.ReadFile(m.migrations, m.downFile(name))
+		if err != nil {
+			return fmt.Errorf("failed to read down migration file: %w", err)
+		}
+	}
+
+	// Start a transaction
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to start transaction: %w", err)
 	}
+	defer func() {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil && rollbackErr != sql.ErrTxDone {
+			if err == nil {
+				err = fmt.Errorf("failed to rollback transaction: %w", rollbackErr)
+			} else {
+				log.Printf("rollback error suppressed: %v", rollbackErr)
+			}
+		}
+	}()
 
-	// Apply each migration
-	for _, name := range pending {
-		log.Printf("Applying migration: %s", name)
-		if err := m.ApplyMigration(ctx, name); err != nil {
-			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+	// Execute down migration SQL, or run the Go down function
+	if isGo {
+		if err := gm.down(ctx, tx, newORM(tx)); err != nil {
+			return fmt.Errorf("failed to run down migration: %w", err)
 		}
-		log.Printf("Migration applied: %s", name)
+	} else if _, err := tx.ExecContext(ctx, string(content)); err != nil {
+		return fmt.Errorf("failed to execute down migration: %w", err)
+	}
+
+	// Remove the migration record
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE migration_name = $1", m.migrationsTable)
+	if _, err := tx.ExecContext(ctx, deleteQuery, name); err != nil {
+		return fmt.Errorf("failed to remove migration record: %w", err)
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-// ApplyAllMigrations applies all migrations regardless of what's already applied
-func (m *Manager) ApplyAllMigrations(ctx context.Context) error {
-	// Get available migration files
-	files, err := filepath.Glob(filepath.Join(m.migrationsDir, "*.sql"))
+// Rollback reverts the given number of most recently applied migrations
+func (m *Manager) Rollback(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("rollback steps must be at least 1, got %d", steps)
+	}
+
+	return m.withLock(ctx, func() error {
+		// Get applied migrations, most recent first
+		applied, err := m.appliedNewestFirst(ctx)
+		if err != nil {
+			return err
+		}
+
+		if steps > len(applied) {
+			steps = len(applied)
+		}
+
+		return m.rollbackMigrations(ctx, applied[:steps])
+	})
+}
+
+// MigrateTo applies or reverts migrations so that the given version is the latest one applied.
+// The version is the numeric prefix of the migration name before the first underscore, such as 003 or 3.
+// Version 0 reverts every applied migration.
+func (m *Manager) MigrateTo(ctx context.Context, version string) error {
+	target, err := strconv.ParseInt(version, 10, 64)
+	if err != nil || target < 0 {
+		return fmt.Errorf("invalid migration version %q", version)
+	}
+
+	return m.withLock(ctx, func() error {
+		// Get available migrations
+		names, err := m.migrationNames()
+		if err != nil {
+			return err
+		}
+
+		// Make sure the target version exists
+		if target != 0 && !slices.ContainsFunc(names, func(name string) bool {
+			v, _ := migrationVersion(name)
+			return v == target
+		}) {
+			return fmt.Errorf("migration version %s not found", version)
+		}
+
+		// Revert applied migrations newer than the target
+		applied, err := m.appliedNewestFirst(ctx)
+		if err != nil {
+			return err
+		}
+
+		var revert []string
+		for _, name := range applied {
+			v, err := migrationVersion(name)
+			if err != nil {
+				return err
+			}
+			if v > target {
+				revert = append(revert, name)
+			}
+		}
+
+		if err := m.rollbackMigrations(ctx, revert); err != nil {
+			return err
+		}
+
+		// Apply pending migrations up to and including the target
+		pending, err := m.GetPendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range pending {
+			if v, _ := migrationVersion(name); v > target {
+				break
+			}
+
+			log.Printf("Applying migration: %s", name)
+			if err := m.ApplyMigration(ctx, name); err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", name, err)
+			}
+			log.Printf("Migration applied: %s", name)
+		}
+
+		return nil
+	})
+}
+
+// rollbackMigrations reverts the given migrations in order, after checking they all have a down file or function
+func (m *Manager) rollbackMigrations(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if gm, ok := m.goMigrations[name]; ok {
+			if gm.down == nil {
+				return fmt.Errorf("migration %s cannot be rolled back: go migration has no down function", name)
+			}
+			continue
+		}
+		if _, err := fs.Stat(m.migrations, m.downFile(name)); err != nil {
+			return fmt.Errorf("migration %s cannot be rolled back: %w", name, err)
+		}
+	}
+
+	for _, name := range names {
+		log.Printf("Rolling back migration: %s", name)
+		if err := m.RollbackMigration(ctx, name); err != nil {
+			return fmt.Errorf("failed to roll back migration %s: %w", name, err)
+		}
+		log.Printf("Migration rolled back: %s", name)
+	}
+
+	return nil
+}
+
+// appliedNewestFirst returns the distinct applied migrations, most recently applied first
+func (m *Manager) appliedNewestFirst(ctx context.Context) ([]string, error) {
+	applied, err := m.GetAppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		if !seen[applied[i]] {
+			seen[applied[i]] = true
+			names = append(names, applied[i])
+		}
+	}
+
+	return names, nil
+}
+
+// migrationNames returns the sorted names of the available SQL and Go migrations.
+// Up migrations are named <name>.up.sql, or <name>.sql for those without a down migration,
+// and down migrations are named <name>.down.sql.
+func (m *Manager) migrationNames() ([]string, error) {
+	files, err := fs.Glob(m.migrations, "*.sql")
 	if err != nil {
-		return fmt.Errorf("failed to list migration files: %w", err)
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
 	}
 
-	// Extract migration names
+	seen := make(map[string]bool)
 	var names []string
 	for _, file := range files {
-		name := filepath.Base(file)
-		name = strings.TrimSuffix(name, ".sql")
-		names = append(names, name)
+		if strings.HasSuffix(file, downSuffix) {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimSuffix(file, upSuffix), ".sql")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
 
-	// Sort migrations by name
-	sort.Strings(names)
+	for name := range m.goMigrations {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
 
-	// Apply each migration
+	// Sort migrations by version, so versions of different widths are ordered numerically
+	versions := make(map[string]int64, len(names))
 	for _, name := range names {
-		log.Printf("Applying migration: %s", name)
-		if err := m.ApplyMigration(ctx, name); err != nil {
-			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+		v, err := migrationVersion(name)
+		if err != nil {
+			return nil, err
 		}
-		log.Printf("Migration applied: %s", name)
+		versions[name] = v
 	}
+	sort.Slice(names, func(i, j int) bool {
+		if versions[names[i]] != versions[names[j]] {
+			return versions[names[i]] < versions[names[j]]
+		}
+		return names[i] < names[j]
+	})
 
-	return nil
+	return names, nil
+}
+
+// upFile returns the name of the up migration file for the given migration
+func (m *Manager) upFile(name string) string {
+	if _, err := fs.Stat(m.migrations, name+upSuffix); err == nil {
+		return name + upSuffix
+	}
+	return name + ".sql"
+}
+
+// downFile returns the name of the down migration file for the given migration
+func (m *Manager) downFile(name string) string {
+	return name + downSuffix
+}
+
+// versionPrefix returns the version prefix of a migration name, as written
+func versionPrefix(name string) string {
+	prefix, _, _ := strings.Cut(name, "_")
+	return prefix
+}
+
+// migrationVersion returns the numeric version prefix of a migration name
+func migrationVersion(name string) (int64, error) {
+	version, err := strconv.ParseInt(versionPrefix(name), 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("migration %s does not start with a numeric version", name)
+	}
+	return version, nil
 }
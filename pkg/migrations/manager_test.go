@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(files ...string) *Manager {
+	fsys := fstest.MapFS{}
+	for _, file := range files {
+		fsys[file] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	}
+	return NewManager(nil, fsys, "test_migrations")
+}
+
+func TestManager_MigrationNames(t *testing.T) {
+	m := newTestManager(
+		"002_second.up.sql",
+		"002_second.down.sql",
+		"001_first.sql",
+		"003_third.up.sql",
+		"README.md",
+	)
+
+	names, err := m.migrationNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"001_first", "002_second", "003_third"}, names)
+
+	assert.Equal(t, "001_first.sql", m.upFile("001_first"))
+	assert.Equal(t, "002_second.up.sql", m.upFile("002_second"))
+	assert.Equal(t, "002_second.down.sql", m.downFile("002_second"))
+}
+
+func TestManager_Rollback(t *testing.T) {
+	m := newTestManager("001_first.sql", "002_second.up.sql", "002_second.down.sql")
+	ctx := context.Background()
+
+	assert.Error(t, m.Rollback(ctx, 0))
+
+	// Nothing is rolled back unless every migration has a down file.
+	err := m.rollbackMigrations(ctx, []string{"002_second", "001_first"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "001_first cannot be rolled back")
+}
+
+func TestMigrationVersion(t *testing.T) {
+	for name, want := range map[string]int64{
+		"003_river_pending_and_more":             3,
+		"20250426174645_create_users_and_tokens": 20250426174645,
+		"001":                                    1,
+	} {
+		v, err := migrationVersion(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, v, name)
+	}
+
+	_, err := migrationVersion("first_migration")
+	assert.Error(t, err)
+}
+
+func TestManager_MigrationNames_NumericOrder(t *testing.T) {
+	m := newTestManager("10_tenth.sql", "9_ninth.sql", "100_hundredth.sql")
+	names, err := m.migrationNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9_ninth", "10_tenth", "100_hundredth"}, names)
+
+	m = newTestManager("001_first.sql", "second.sql")
+	_, err = m.migrationNames()
+	assert.Error(t, err)
+}
+
+func TestManager_MigrateTo_InvalidVersion(t *testing.T) {
+	m := newTestManager("001_first.sql")
+	ctx := context.Background()
+
+	assert.ErrorContains(t, m.MigrateTo(ctx, "abc"), "invalid migration version")
+	assert.ErrorContains(t, m.MigrateTo(ctx, "-1"), "invalid migration version")
+}
+
+func TestNewRiverManager(t *testing.T) {
+	names, err := NewRiverManager(nil).migrationNames()
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+	assert.Equal(t, "001_create_tables", names[0])
+}
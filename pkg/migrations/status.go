@@ -0,0 +1,132 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// Status describes an available or applied migration
+type Status struct {
+	Name string
+
+	// AppliedAt is when the migration was applied, or zero if it is pending
+	AppliedAt time.Time
+
+	// Missing is set when an applied migration no longer has a file
+	Missing bool
+}
+
+// Pending returns true if the migration has not been applied
+func (s Status) Pending() bool {
+	return s.AppliedAt.IsZero()
+}
+
+// PlannedMigration is a pending migration and the SQL which applying it would execute
+type PlannedMigration struct {
+	Name string
+	SQL  string
+}
+
+// Status returns every available migration in the order they are applied, followed by any applied migrations
+// which are no longer available. Unlike the methods which apply migrations, it does not create the migrations
+// table.
+func (m *Manager) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.appliedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := m.migrationNames()
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]bool, len(names))
+	statuses := make([]Status, 0, len(names))
+	for _, name := range names {
+		available[name] = true
+		statuses = append(statuses, Status{Name: name, AppliedAt: applied[name]})
+	}
+
+	var missing []Status
+	for name, at := range applied {
+		if !available[name] {
+			missing = append(missing, Status{Name: name, AppliedAt: at, Missing: true})
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].AppliedAt.Before(missing[j].AppliedAt)
+	})
+
+	return append(statuses, missing...), nil
+}
+
+// Plan returns the pending migrations in the order they would be applied, without executing them
+func (m *Manager) Plan(ctx context.Context) ([]PlannedMigration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []PlannedMigration
+	for _, s := range statuses {
+		if !s.Pending() {
+			continue
+		}
+
+		if _, ok := m.goMigrations[s.Name]; ok {
+			plan = append(plan, PlannedMigration{Name: s.Name, SQL: "-- go migration, its statements are only known when it runs"})
+			continue
+		}
+
+		content, err := fs.ReadFile(m.migrations, m.upFile(s.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file: %w", err)
+		}
+		plan = append(plan, PlannedMigration{Name: s.Name, SQL: string(content)})
+	}
+
+	return plan, nil
+}
+
+// appliedAt returns when each applied migration was first applied, or nothing if the migrations table
+// does not exist yet
+func (m *Manager) appliedAt(ctx context.Context) (applied map[string]time.Time, err error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", m.migrationsTable).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check for migrations table: %w", err)
+	}
+
+	applied = make(map[string]time.Time)
+	if !exists {
+		return applied, nil
+	}
+
+	query := fmt.Sprintf("SELECT migration_name, MIN(applied_at) FROM %s GROUP BY migration_name", m.migrationsTable)
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer func() {
+		closeErr := rows.Close()
+		if err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close rows: %w", closeErr)
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			name string
+			at   time.Time
+		)
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		applied[name] = at
+	}
+
+	return applied, rows.Err()
+}
@@ -22,10 +22,16 @@ const (
 	Search               = "search"
 	Task                 = "task"
 	TaskSubmit           = "task.submit"
+	TaskStatus           = "task.status"
 	Cache                = "cache"
 	CacheSubmit          = "cache.submit"
 	Files                = "files"
 	FilesSubmit          = "files.submit"
+	AdminTasks           = "admin:tasks"
+	AdminTasksSubmit     = "admin:tasks.submit"
+	AdminTaskQueues      = "admin:task_queues"
+	AdminTask            = "admin:task"
+	AdminTaskSubmit      = "admin:task.submit"
 )
 
 func AdminEntityList(entityTypeName string) string {
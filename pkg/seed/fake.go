@@ -0,0 +1,44 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// FakePassword is the password of every fake user
+const FakePassword = "password"
+
+var (
+	firstNames = []string{
+		"Amara", "Ben", "Chloe", "Daniel", "Elena", "Felix", "Grace", "Hugo", "Isla", "James",
+		"Kenji", "Laura", "Mateo", "Nadia", "Oliver", "Priya", "Quinn", "Rosa", "Samuel", "Tara",
+		"Umar", "Vera", "William", "Ximena", "Yusuf", "Zoe",
+	}
+	lastNames = []string{
+		"Adams", "Bauer", "Castillo", "Dubois", "Evans", "Fischer", "Garcia", "Hansen", "Ito", "Jensen",
+		"Kowalski", "Larsen", "Martin", "Novak", "Okafor", "Patel", "Rossi", "Silva", "Tanaka", "Weber",
+	}
+)
+
+// Fake returns the given number of fake users. The users are the same on every call, so seeding them again
+// updates rather than duplicates them.
+func Fake(n int) []User {
+	// A fixed seed keeps the users stable between calls
+	r := rand.New(rand.NewPCG(1, 2))
+
+	users := make([]User, 0, n)
+	for i := 1; i <= n; i++ {
+		first := firstNames[r.IntN(len(firstNames))]
+		last := lastNames[r.IntN(len(lastNames))]
+
+		users = append(users, User{
+			Name:     first + " " + last,
+			Email:    fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i),
+			Password: FakePassword,
+			Verified: r.IntN(10) < 8,
+		})
+	}
+
+	return users
+}
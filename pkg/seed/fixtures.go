@@ -0,0 +1,187 @@
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fixtures are the entities to seed the database with
+type Fixtures struct {
+	Users          []User          `yaml:"users" json:"users"`
+	PasswordTokens []PasswordToken `yaml:"passwordTokens" json:"passwordTokens"`
+}
+
+// User is a user fixture, which is matched to an existing user by email
+type User struct {
+	// Ref is the name other fixtures use to reference the user
+	Ref string `yaml:"ref" json:"ref"`
+
+	Name  string `yaml:"name" json:"name"`
+	Email string `yaml:"email" json:"email"`
+
+	// Password is only set when the user is created, so passwords changed since are kept
+	Password string `yaml:"password" json:"password"`
+
+	Admin    bool `yaml:"admin" json:"admin"`
+	Verified bool `yaml:"verified" json:"verified"`
+}
+
+// PasswordToken is a password reset token fixture, which is created unless the user already has the token
+type PasswordToken struct {
+	// User is the ref of the user the token belongs to
+	User  string `yaml:"user" json:"user"`
+	Token string `yaml:"token" json:"token"`
+}
+
+// Load reads the fixture files in the root of the file system followed by those in the directory of the
+// given environment, ie local/users.yaml. Files are read in order of their names and can be YAML or JSON.
+// Fixtures in later files replace earlier ones with the same ref, or email for users without a ref, so an
+// environment can override the shared fixtures.
+func Load(fsys fs.FS, env string) (*Fixtures, error) {
+	files, err := fixtureFiles(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	if env != "" {
+		envFiles, err := fixtureFiles(fsys, env)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		files = append(files, envFiles...)
+	}
+
+	var f Fixtures
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture file: %w", err)
+		}
+
+		var parsed Fixtures
+		if path.Ext(file) == ".json" {
+			err = json.Unmarshal(content, &parsed)
+		} else {
+			err = yaml.Unmarshal(content, &parsed)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture file %s: %w", file, err)
+		}
+
+		f.merge(parsed)
+	}
+
+	return &f, f.Validate()
+}
+
+// fixtureFiles returns the sorted YAML and JSON files in a directory
+func fixtureFiles(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		switch path.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, path.Join(dir, entry.Name()))
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// merge adds the given fixtures, replacing any with the same key
+func (f *Fixtures) merge(other Fixtures) {
+	for _, u := range other.Users {
+		if i := f.userIndex(u.key()); i >= 0 {
+			f.Users[i] = u
+		} else {
+			f.Users = append(f.Users, u)
+		}
+	}
+
+	for _, t := range other.PasswordTokens {
+		replaced := false
+		for i := range f.PasswordTokens {
+			if f.PasswordTokens[i].User == t.User {
+				f.PasswordTokens[i] = t
+				replaced = true
+			}
+		}
+		if !replaced {
+			f.PasswordTokens = append(f.PasswordTokens, t)
+		}
+	}
+}
+
+// userIndex returns the index of the user with the given key, or -1
+func (f *Fixtures) userIndex(key string) int {
+	for i, u := range f.Users {
+		if u.key() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// key returns what identifies the user across fixture files
+func (u User) key() string {
+	if u.Ref != "" {
+		return "ref:" + u.Ref
+	}
+	return "email:" + strings.ToLower(u.Email)
+}
+
+// Validate returns every problem with the fixtures, including references to users which do not exist
+func (f *Fixtures) Validate() error {
+	var errs []error
+	refs := make(map[string]bool)
+	emails := make(map[string]bool)
+
+	for i, u := range f.Users {
+		switch {
+		case u.Name == "":
+			errs = append(errs, fmt.Errorf("user %d: name is required", i+1))
+		case u.Email == "":
+			errs = append(errs, fmt.Errorf("user %s: email is required", u.Name))
+		case u.Password == "":
+			errs = append(errs, fmt.Errorf("user %s: password is required", u.Email))
+		}
+
+		email := strings.ToLower(u.Email)
+		if email != "" && emails[email] {
+			errs = append(errs, fmt.Errorf("user %s: duplicate email", u.Email))
+		}
+		emails[email] = true
+
+		if u.Ref != "" {
+			if refs[u.Ref] {
+				errs = append(errs, fmt.Errorf("user %s: duplicate ref %q", u.Email, u.Ref))
+			}
+			refs[u.Ref] = true
+		}
+	}
+
+	for _, t := range f.PasswordTokens {
+		switch {
+		case !refs[t.User]:
+			errs = append(errs, fmt.Errorf("password token: unknown user ref %q", t.User))
+		case t.Token == "":
+			errs = append(errs, fmt.Errorf("password token of %s: token is required", t.User))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,97 @@
+package seed
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"users.yaml": &fstest.MapFile{Data: []byte(`
+users:
+  - ref: admin
+    name: Admin
+    email: admin@example.com
+    password: password
+    admin: true
+`)},
+		"local/users.json": &fstest.MapFile{Data: []byte(`{
+  "users": [
+    {"ref": "admin", "name": "Local Admin", "email": "admin@example.com", "password": "password", "admin": true},
+    {"ref": "demo", "name": "Demo", "email": "demo@example.com", "password": "password"}
+  ],
+  "passwordTokens": [{"user": "demo", "token": "abc"}]
+}`)},
+		"local/README.md": &fstest.MapFile{Data: []byte("# Ignored")},
+	}
+
+	f, err := Load(fsys, "local")
+	require.NoError(t, err)
+	require.Len(t, f.Users, 2)
+	assert.Equal(t, "Local Admin", f.Users[0].Name)
+	assert.Equal(t, "demo", f.Users[1].Ref)
+	assert.Equal(t, []PasswordToken{{User: "demo", Token: "abc"}}, f.PasswordTokens)
+
+	// Environments without fixtures only load the shared ones.
+	f, err = Load(fsys, "prod")
+	require.NoError(t, err)
+	require.Len(t, f.Users, 1)
+	assert.Equal(t, "Admin", f.Users[0].Name)
+	assert.Empty(t, f.PasswordTokens)
+}
+
+func TestFixtures_Validate(t *testing.T) {
+	f := Fixtures{
+		Users: []User{
+			{Ref: "a", Name: "A", Email: "a@example.com", Password: "password"},
+			{Ref: "a", Name: "B", Email: "A@example.com", Password: "password"},
+			{Name: "C", Email: "c@example.com"},
+		},
+		PasswordTokens: []PasswordToken{
+			{User: "missing", Token: "abc"},
+			{User: "a"},
+		},
+	}
+
+	err := f.Validate()
+	require.Error(t, err)
+	for _, msg := range []string{
+		"duplicate email",
+		`duplicate ref "a"`,
+		"user c@example.com: password is required",
+		`unknown user ref "missing"`,
+		"password token of a: token is required",
+	} {
+		assert.Contains(t, err.Error(), msg)
+	}
+}
+
+func TestFake(t *testing.T) {
+	users := Fake(20)
+	require.Len(t, users, 20)
+	assert.Equal(t, users, Fake(20))
+
+	f := Fixtures{Users: users}
+	assert.NoError(t, f.Validate())
+}
+
+func TestLoad_Seeds(t *testing.T) {
+	seeds := os.DirFS("../../seeds")
+
+	f, err := Load(seeds, "local")
+	require.NoError(t, err)
+	assert.True(t, slices.ContainsFunc(f.Users, func(u User) bool { return u.Admin }))
+	assert.NotEmpty(t, f.PasswordTokens)
+
+	// The public preview has no well-known admin or password tokens.
+	f, err = Load(seeds, "preview")
+	require.NoError(t, err)
+	assert.NotEmpty(t, f.Users)
+	assert.False(t, slices.ContainsFunc(f.Users, func(u User) bool { return u.Admin }))
+	assert.Empty(t, f.PasswordTokens)
+}
@@ -0,0 +1,139 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/edkadigital/startmeup/ent"
+	"github.com/edkadigital/startmeup/ent/passwordtoken"
+	"github.com/edkadigital/startmeup/ent/user"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Result counts the entities a seed created, updated and left unchanged
+type Result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged", r.Created, r.Updated, r.Unchanged)
+}
+
+// Seed upserts the fixtures in a single transaction, so seeding again with the same fixtures changes nothing
+// and a failed seed leaves the database as it was
+func Seed(ctx context.Context, orm *ent.Client, f *Fixtures) (res Result, err error) {
+	tx, err := orm.Tx(ctx)
+	if err != nil {
+		return res, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// Map user refs to IDs, for the fixtures which reference them
+	refs := make(map[string]int, len(f.Users))
+	for _, u := range f.Users {
+		id, err := upsertUser(ctx, tx, u, &res)
+		if err != nil {
+			return res, err
+		}
+		if u.Ref != "" {
+			refs[u.Ref] = id
+		}
+	}
+
+	for _, t := range f.PasswordTokens {
+		userID, ok := refs[t.User]
+		if !ok {
+			return res, fmt.Errorf("password token: unknown user ref %q", t.User)
+		}
+		if err := upsertPasswordToken(ctx, tx, userID, t, &res); err != nil {
+			return res, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return res, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return res, nil
+}
+
+// upsertUser creates the user or updates the user with the same email, returning its ID
+func upsertUser(ctx context.Context, tx *ent.Tx, u User, res *Result) (int, error) {
+	existing, err := tx.User.
+		Query().
+		Where(user.Email(strings.ToLower(u.Email))).
+		Only(ctx)
+
+	switch {
+	case ent.IsNotFound(err):
+		created, err := tx.User.
+			Create().
+			SetName(u.Name).
+			SetEmail(u.Email).
+			SetPassword(u.Password).
+			SetAdmin(u.Admin).
+			SetVerified(u.Verified).
+			Save(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create user %s: %w", u.Email, err)
+		}
+		res.Created++
+		return created.ID, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to query user %s: %w", u.Email, err)
+	}
+
+	if existing.Name == u.Name && existing.Admin == u.Admin && existing.Verified == u.Verified {
+		res.Unchanged++
+		return existing.ID, nil
+	}
+
+	err = existing.
+		Update().
+		SetName(u.Name).
+		SetAdmin(u.Admin).
+		SetVerified(u.Verified).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update user %s: %w", u.Email, err)
+	}
+	res.Updated++
+	return existing.ID, nil
+}
+
+// upsertPasswordToken creates the token unless the user already has it. Tokens are stored hashed, so they
+// are compared rather than queried.
+func upsertPasswordToken(ctx context.Context, tx *ent.Tx, userID int, t PasswordToken, res *Result) error {
+	existing, err := tx.PasswordToken.
+		Query().
+		Where(passwordtoken.UserID(userID)).
+		All(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to query password tokens of %s: %w", t.User, err)
+	}
+
+	for _, pt := range existing {
+		if bcrypt.CompareHashAndPassword([]byte(pt.Token), []byte(t.Token)) == nil {
+			res.Unchanged++
+			return nil
+		}
+	}
+
+	err = tx.PasswordToken.
+		Create().
+		SetUserID(userID).
+		SetToken(t.Token).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create password token of %s: %w", t.User, err)
+	}
+	res.Created++
+	return nil
+}
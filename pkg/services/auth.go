@@ -1,6 +1,7 @@
 package services
 
 import (
+	goctx "context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -174,6 +175,17 @@ func (c *AuthClient) DeletePasswordTokens(ctx echo.Context, userID int) error {
 	return err
 }
 
+// DeleteExpiredPasswordTokens deletes all password tokens in the database which have expired and returns the
+// amount that were deleted.
+func (c *AuthClient) DeleteExpiredPasswordTokens(ctx goctx.Context) (int, error) {
+	expiration := time.Now().Add(-c.config.App.PasswordToken.Expiration)
+
+	return c.orm.PasswordToken.
+		Delete().
+		Where(passwordtoken.CreatedAtLT(expiration)).
+		Exec(ctx)
+}
+
 // RandomToken generates a random token string of a given length
 func (c *AuthClient) RandomToken(length int) (string, error) {
 	b := make([]byte, (length/2)+1)
@@ -105,6 +105,32 @@ func TestAuthClient_DeletePasswordTokens(t *testing.T) {
 	assert.Equal(t, 0, count)
 }
 
+func TestAuthClient_DeleteExpiredPasswordTokens(t *testing.T) {
+	// Create a valid and an expired token for the user
+	_, valid, err := c.Auth.GeneratePasswordResetToken(ctx, usr.ID)
+	require.NoError(t, err)
+	_, expired, err := c.Auth.GeneratePasswordResetToken(ctx, usr.ID)
+	require.NoError(t, err)
+	err = c.ORM.PasswordToken.
+		UpdateOne(expired).
+		SetCreatedAt(time.Now().Add(-(c.Config.App.PasswordToken.Expiration + time.Hour))).
+		Exec(context.Background())
+	require.NoError(t, err)
+
+	// Delete the expired tokens
+	count, err := c.Auth.DeleteExpiredPasswordTokens(context.Background())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1)
+
+	// Check that only the valid token remains
+	exists, err := c.ORM.PasswordToken.Query().Where(passwordtoken.ID(expired.ID)).Exist(context.Background())
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = c.ORM.PasswordToken.Query().Where(passwordtoken.ID(valid.ID)).Exist(context.Background())
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
 func TestAuthClient_RandomToken(t *testing.T) {
 	length := c.Config.App.PasswordToken.Length
 	a, err := c.Auth.RandomToken(length)
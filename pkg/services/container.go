@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -104,33 +105,38 @@ func NewContainer() *Container {
 }
 
 // Shutdown gracefully shuts the Container down and disconnects all connections.
+// Every service is shut down even if another fails, and the errors are joined.
 func (c *Container) Shutdown() error {
+	var errs []error
+
 	// Shutdown the web server.
 	webCtx, webCancel := context.WithTimeout(context.Background(), c.Config.HTTP.ShutdownTimeout)
 	defer webCancel()
 	if err := c.Web.Shutdown(webCtx); err != nil {
-		return err
+		errs = append(errs, fmt.Errorf("web: %w", err))
 	}
 
 	// Shutdown the task runner.
 	taskCtx, taskCancel := context.WithTimeout(context.Background(), c.Config.Tasks.ShutdownTimeout)
 	defer taskCancel()
-	c.Tasks.Stop(taskCtx)
+	if err := c.Tasks.Stop(taskCtx); err != nil {
+		errs = append(errs, fmt.Errorf("tasks: %w", err))
+	}
 
 	// Shutdown the ORM.
 	if err := c.ORM.Close(); err != nil {
-		return err
+		errs = append(errs, fmt.Errorf("orm: %w", err))
 	}
 
 	// Shutdown the database.
 	if err := c.Database.Close(); err != nil {
-		return err
+		errs = append(errs, fmt.Errorf("database: %w", err))
 	}
 
 	// Shutdown the cache.
 	c.Cache.Close()
 
-	return nil
+	return errors.Join(errs...)
 }
 
 // initConfig initializes configuration.
@@ -139,6 +145,9 @@ func (c *Container) initConfig() {
 	if err != nil {
 		panic(fmt.Sprintf("failed to load config: %v", err))
 	}
+	if err := cfg.Validate(); err != nil {
+		panic(err.Error())
+	}
 	c.Config = &cfg
 
 	slog.Debug("Detected App Environment", "environment", c.Config.App.Environment)
@@ -255,11 +264,17 @@ func (c *Container) initMail() {
 
 // initTasks initializes the River worker.
 func (c *Container) initTasks() {
+	// Store tasks in memory for tests.
+	if c.Config.App.Environment == config.EnvTest {
+		c.Tasks = riveradapter.NewMemoryWorker(c.Config.Tasks)
+		return
+	}
+
 	var err error
 
 	// Create the River worker - migrations will be handled separately
 	// by the migrate command, not during application startup
-	c.Tasks, err = riveradapter.NewWorker(c.Database)
+	c.Tasks, err = riveradapter.NewWorker(c.Database, c.Config.Tasks)
 	if err != nil {
 		panic(fmt.Sprintf("failed to create River worker: %v", err))
 	}
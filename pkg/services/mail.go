@@ -2,7 +2,9 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"log/slog"
 
 	"github.com/edkadigital/startmeup/config"
 	"github.com/edkadigital/startmeup/pkg/log"
@@ -53,7 +55,7 @@ func (m *MailClient) skipSend() bool {
 }
 
 // send attempts to send the email.
-func (m *MailClient) send(email *mail, ctx echo.Context) error {
+func (m *MailClient) send(email *mail, logger *slog.Logger) error {
 	switch {
 	case email.to == "":
 		return errors.New("email cannot be sent without a to address")
@@ -75,14 +77,14 @@ func (m *MailClient) send(email *mail, ctx echo.Context) error {
 
 	// Check if mail sending should be skipped.
 	if m.skipSend() {
-		log.Ctx(ctx).Debug("skipping email delivery",
+		logger.Debug("skipping email delivery",
 			"to", email.to,
 		)
 		return nil
 	}
 
 	// TODO: Finish based on your mail sender of choice or stop logging below!
-	log.Ctx(ctx).Info("sending email",
+	logger.Info("sending email",
 		"to", email.to,
 		"subject", email.subject,
 		"body", email.body,
@@ -123,5 +125,10 @@ func (m *mail) Component(component gomponents.Node) *mail {
 
 // Send attempts to send the email.
 func (m *mail) Send(ctx echo.Context) error {
-	return m.client.send(m, ctx)
+	return m.client.send(m, log.Ctx(ctx))
+}
+
+// SendContext attempts to send the email outside of an HTTP request, such as from a task.
+func (m *mail) SendContext(ctx context.Context) error {
+	return m.client.send(m, log.FromContext(ctx))
 }
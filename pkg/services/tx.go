@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/edkadigital/startmeup/ent"
+)
+
+// Tx is a database transaction shared by the ORM and the task queue, so that entities and the tasks which
+// depend on them are committed, or rolled back, together.
+type Tx struct {
+	// ORM is an ORM client which operates within the transaction.
+	ORM *ent.Client
+
+	// SQL is the underlying transaction, which tasks can be inserted in via riveradapter.InsertTx().
+	SQL *sql.Tx
+}
+
+// WithTx runs fn within a new transaction which is committed if fn returns nil and rolled back otherwise.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *Tx) error) error {
+	sqlTx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = sqlTx.Rollback()
+	}()
+
+	tx := &Tx{
+		ORM: ent.NewClient(ent.Driver(entsql.NewDriver(dialect.Postgres, entsql.Conn{ExecQuerier: sqlTx}))),
+		SQL: sqlTx,
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
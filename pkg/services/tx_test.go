@@ -0,0 +1,44 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/edkadigital/startmeup/ent/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTx(t *testing.T) {
+	create := func(tx *Tx, email string) error {
+		return tx.ORM.User.
+			Create().
+			SetName("Tx").
+			SetEmail(email).
+			SetPassword("password").
+			Exec(context.Background())
+	}
+
+	exists := func(email string) bool {
+		found, err := c.ORM.User.Query().Where(user.Email(email)).Exist(context.Background())
+		require.NoError(t, err)
+		return found
+	}
+
+	// Committed.
+	err := WithTx(context.Background(), c.Database, func(tx *Tx) error {
+		return create(tx, "tx-commit@localhost.localhost")
+	})
+	require.NoError(t, err)
+	assert.True(t, exists("tx-commit@localhost.localhost"))
+
+	// Rolled back.
+	errRollback := errors.New("rollback")
+	err = WithTx(context.Background(), c.Database, func(tx *Tx) error {
+		require.NoError(t, create(tx, "tx-rollback@localhost.localhost"))
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	assert.False(t, exists("tx-rollback@localhost.localhost"))
+}
@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/edkadigital/startmeup/ent/user"
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/services"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
+	"github.com/edkadigital/startmeup/pkg/ui/emails"
+)
+
+// NewDiscardNotifier provides a hook which emails all admins when a critical task fails for the last time.
+// Register it for a task kind with riveradapter.OnDiscard().
+func NewDiscardNotifier(c *services.Container) riveradapter.DiscardHook {
+	return func(ctx context.Context, task riveradapter.DiscardedTask) error {
+		admins, err := c.ORM.User.
+			Query().
+			Where(user.Admin(true)).
+			All(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load admins: %w", err)
+		}
+
+		url := c.Config.App.Host + c.Web.Reverse(routenames.AdminTask, task.ID)
+
+		var errs []error
+		for _, admin := range admins {
+			err := c.Mail.
+				Compose().
+				To(admin.Email).
+				Subject(fmt.Sprintf("Task failed: %s", task.Kind)).
+				Component(emails.TaskDiscarded(task.Kind, task.ID, task.Attempt, task.Error.Error(), url)).
+				SendContext(ctx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to email %s: %w", admin.Email, err))
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/services"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
+)
+
+// exampleTaskSteps is the number of steps the example task takes to complete, one second each.
+const exampleTaskSteps = 5
+
+// ExampleTask is an example task which logs the provided message.
+// This represents the task that can be inserted in to the queue and should contain everything that the
+// handler needs to process it.
+type ExampleTask struct {
+	Message string `json:"message"`
+}
+
+// Kind returns the kind used to route the task to its handler.
+func (ExampleTask) Kind() string {
+	return "example_task"
+}
+
+// NewExampleTaskHandler provides a handler that processes ExampleTask tasks.
+// To demonstrate progress reporting, this works through a number of steps before logging the message.
+func NewExampleTaskHandler(c *services.Container) func(ctx context.Context, task ExampleTask) error {
+	return func(ctx context.Context, task ExampleTask) error {
+		for step := 1; step <= exampleTaskSteps; step++ {
+			err := riveradapter.ReportProgress(ctx,
+				(step-1)*100/exampleTaskSteps,
+				fmt.Sprintf("Working on step %d of %d", step, exampleTaskSteps),
+			)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+
+		log.FromContext(ctx).Info("Example task received",
+			"message", task.Message,
+		)
+		log.FromContext(ctx).Info("This can access the container for dependencies",
+			"echo", c.Web.Reverse(routenames.Home),
+		)
+
+		return riveradapter.ReportProgress(ctx, 100, "Logged the message")
+	}
+}
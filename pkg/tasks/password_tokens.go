@@ -0,0 +1,32 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/edkadigital/startmeup/pkg/services"
+)
+
+// DeleteExpiredPasswordTokensTask is a task which deletes all password tokens that have expired.
+// This is inserted periodically, see Register().
+type DeleteExpiredPasswordTokensTask struct{}
+
+// Kind returns the kind used to route the task to its handler.
+func (DeleteExpiredPasswordTokensTask) Kind() string {
+	return "delete_expired_password_tokens"
+}
+
+// NewDeleteExpiredPasswordTokensHandler provides a handler that processes DeleteExpiredPasswordTokensTask tasks.
+func NewDeleteExpiredPasswordTokensHandler(c *services.Container) func(ctx context.Context, task DeleteExpiredPasswordTokensTask) error {
+	return func(ctx context.Context, task DeleteExpiredPasswordTokensTask) error {
+		count, err := c.Auth.DeleteExpiredPasswordTokens(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.FromContext(ctx).Info("deleted expired password tokens",
+			"count", count,
+		)
+		return nil
+	}
+}
@@ -1,27 +1,56 @@
 package tasks
 
 import (
-	"context"
+	"time"
 
-	"github.com/edkadigital/startmeup/pkg/log"
-	"github.com/edkadigital/startmeup/pkg/routenames"
 	"github.com/edkadigital/startmeup/pkg/services"
 	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
 )
 
-// Register registers all task workers with the task client.
+// QueueMail is the queue for tasks which send email, so they are not held up by other tasks.
+const QueueMail = "mail"
+
+// Register registers all task workers and periodic tasks with the task client.
 func Register(c *services.Container) {
-	// Register the example task worker
-	err := c.Tasks.RegisterExampleTask(func(ctx context.Context, task riveradapter.ExampleTask) error {
-		log.Default().Info("Example task received",
-			"message", task.Message,
-		)
-		log.Default().Info("This can access the container for dependencies",
-			"echo", c.Web.Reverse(routenames.Home),
-		)
-		return nil
-	})
+	// Wrap the processing of every task, similar to the middleware of the web router.
+	c.Tasks.Use(
+		riveradapter.RequestID(),
+		riveradapter.Logger(),
+		riveradapter.Recover(),
+		riveradapter.Timeout(c.Config.Tasks.Timeout),
+	)
+
+	if err := riveradapter.Register(c.Tasks, NewExampleTaskHandler(c)); err != nil {
+		panic(err)
+	}
+
+	if err := riveradapter.Register(c.Tasks, NewDeleteExpiredPasswordTokensHandler(c)); err != nil {
+		panic(err)
+	}
 
+	// Retry welcome emails for several hours, and let the admins know if one could not be sent.
+	err := riveradapter.Register(c.Tasks, NewSendWelcomeEmailHandler(c),
+		riveradapter.WithQueue(QueueMail),
+		riveradapter.WithRetryPolicy(riveradapter.RetryPolicy{
+			MaxAttempts: 10,
+			BaseDelay:   time.Minute,
+			MaxDelay:    6 * time.Hour,
+			Jitter:      0.2,
+		}),
+		riveradapter.OnDiscard(NewDiscardNotifier(c)),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	// Delete expired password tokens every night.
+	nightly, err := riveradapter.Cron("0 3 * * *")
+	if err != nil {
+		panic(err)
+	}
+	err = riveradapter.RegisterPeriodic(c.Tasks, "delete_expired_password_tokens", nightly,
+		DeleteExpiredPasswordTokensTask{},
+	)
 	if err != nil {
 		panic(err)
 	}
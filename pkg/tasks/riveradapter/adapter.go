@@ -3,173 +3,328 @@ package riveradapter
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"time"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
 
+	"github.com/edkadigital/startmeup/config"
+	"github.com/edkadigital/startmeup/pkg/log"
 	"github.com/edkadigital/startmeup/pkg/migrations"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/riverqueue/river"
 )
 
-// ExampleTaskKind is the kind for example tasks
-const ExampleTaskKind = "example_task"
+type (
+	// TaskArgs is implemented by the arguments of every task.
+	// The kind routes a task to its handler and must be unique. The arguments are JSON-encoded when the
+	// task is inserted, so all exported fields are stored unless skipped with a struct tag.
+	TaskArgs interface {
+		Kind() string
+	}
 
-// ExampleTask represents a simple example task
-type ExampleTask struct {
-	Message string
-}
+	// InsertResult describes a task that has been inserted.
+	InsertResult struct {
+		// ID stores the ID of the inserted job.
+		// If the task was a duplicate, this is the ID of the existing job.
+		ID int64
+
+		// Duplicate indicates that the task was not inserted because it was a duplicate of an existing one.
+		// See Unique().
+		Duplicate bool
+	}
+)
 
-// Worker creates and manages River worker instances
+// Worker creates and manages the River client used to enqueue and process tasks
 type Worker struct {
-	// We're simulating the implementation with a placeholder
+	// db stores the connection to the database.
 	db *sql.DB
+
+	// config stores the tasks configuration.
+	config config.TasksConfig
+
+	// workers stores the handlers registered for each task kind.
+	workers *river.Workers
+
+	// kinds stores the options each task kind was registered with.
+	kinds map[string]*kindOptions
+
+	// queues stores the names of the queues that tasks are processed from once started.
+	queues []string
+
+	// middleware stores the middleware which wraps the processing of every task.
+	middleware []MiddlewareFunc
+
+	// periodic stores the periodic tasks, which are inserted once started.
+	periodic []*river.PeriodicJob
+
+	// client stores the River client, which operates on the shared database connection.
+	// It is created on first use, with the queues selected by then, and processes tasks once started.
+	client *river.Client[*sql.Tx]
+
+	// clientMu guards the creation of the client.
+	clientMu sync.Mutex
+
+	// memory stores the tasks instead of River when running tests.
+	memory *Memory
+
+	// hooks tracks the discard hooks which are still running.
+	hooks sync.WaitGroup
 }
 
 // NewWorker creates a new Worker for River
-func NewWorker(db *sql.DB) (*Worker, error) {
-	return &Worker{db: db}, nil
+// Once started, it processes tasks from all configured queues unless SetQueues() is called. Unless the
+// configuration is PollOnly, the database must use the pgx driver so that workers can listen for notifications.
+func NewWorker(db *sql.DB, cfg config.TasksConfig) (*Worker, error) {
+	// Listening for notifications requires the connections of the pgx driver, which only polling does not.
+	if db != nil && !cfg.PollOnly {
+		if _, ok := db.Driver().(*stdlib.Driver); !ok {
+			return nil, fmt.Errorf(
+				"listening for tasks requires the pgx database driver, got %T: use pgx or set tasks.pollOnly",
+				db.Driver(),
+			)
+		}
+	}
+
+	return &Worker{
+		db:      db,
+		config:  cfg,
+		workers: river.NewWorkers(),
+		kinds:   make(map[string]*kindOptions),
+		queues:  slices.Sorted(maps.Keys(cfg.Queues)),
+	}, nil
 }
 
-// Start starts the River worker
-func (w *Worker) Start(ctx context.Context) {
-	log.Println("Starting River worker")
+// riverClient returns the River client, creating it on first use.
+// River only processes the queues a client was created with, so the queues cannot change once it exists.
+func (w *Worker) riverClient() (*river.Client[*sql.Tx], error) {
+	w.clientMu.Lock()
+	defer w.clientMu.Unlock()
+
+	if w.client != nil {
+		return w.client, nil
+	}
+
+	queues := make(map[string]river.QueueConfig, len(w.queues))
+	for _, name := range w.queues {
+		queues[name] = river.QueueConfig{MaxWorkers: w.config.Queues[name]}
+	}
+
+	client, err := w.newClient(queues)
+	if err != nil {
+		return nil, err
+	}
+	w.client = client
+	return client, nil
 }
 
-// Stop stops the River worker
-func (w *Worker) Stop(ctx context.Context) {
-	log.Println("Stopping River worker")
+// newClient creates a River client which processes tasks from the given queues.
+func (w *Worker) newClient(queues map[string]river.QueueConfig) (*river.Client[*sql.Tx], error) {
+	// Inserting a task issues a NOTIFY which wakes idle workers immediately. Workers also poll for
+	// new jobs every PollInterval in case notifications are missed, such as when a connection pooler
+	// in transaction mode drops them, or when listening is disabled with PollOnly.
+	client, err := river.NewClient[*sql.Tx](newDriver(w.db), &river.Config{
+		Queues:               queues,
+		Workers:              w.workers,
+		PeriodicJobs:         append(slices.Clone(w.periodic), w.workflowSweepJob()),
+		PollOnly:             w.config.PollOnly,
+		FetchPollInterval:    w.config.PollInterval,
+		RescueStuckJobsAfter: w.config.ReleaseAfter,
+		JobTimeout:           -1, // Enforced per task kind by the Timeout() middleware instead.
+		RetryPolicy:          &retryPolicy{worker: w, fallback: &river.DefaultClientRetryPolicy{}},
+		ErrorHandler:         &errorHandler{worker: w},
+		Logger:               slog.New(periodicLogHandler{log.Default().Handler()}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create River client: %w", err)
+	}
+	return client, nil
 }
 
-// RegisterExampleTask registers the example task handler
-func (w *Worker) RegisterExampleTask(handler func(ctx context.Context, task ExampleTask) error) error {
-	log.Println("Registering example task handler")
+// SetQueues sets which of the configured queues tasks are processed from once started
+// This allows dedicated workers to be deployed for specific queues. It must be called before any tasks are
+// inserted or the worker is started.
+func (w *Worker) SetQueues(names ...string) error {
+	for _, name := range names {
+		if _, ok := w.config.Queues[name]; !ok {
+			return fmt.Errorf("queue %q is not configured", name)
+		}
+	}
+
+	w.clientMu.Lock()
+	defer w.clientMu.Unlock()
+	if w.client != nil {
+		return errors.New("queues cannot be changed once the worker is in use")
+	}
+
+	w.queues = names
 	return nil
 }
 
-// InsertExampleTask inserts a new example task with the given message
-func (w *Worker) InsertExampleTask(ctx context.Context, message string, delay time.Duration) error {
-	log.Printf("Adding task with message: %s, delay: %v\n", message, delay)
-
-	// Insert directly into the DB using SQL
-	scheduledTime := time.Now().Add(delay)
-
-	// Build the query with parameters
-	query := `
-	INSERT INTO river_jobs (
-		id, 
-		kind, 
-		args, 
-		scheduled_at, 
-		created_at,
-		updated_at,
-		state,
-		queue
-	) VALUES (
-		gen_random_uuid(), 
-		$1, 
-		$2, 
-		$3, 
-		NOW(),
-		NOW(),
-		'available',
-		'default'
-	)
-	`
+// Start starts processing tasks in the background
+// All handlers and periodic tasks must be registered before calling this.
+func (w *Worker) Start(ctx context.Context) error {
+	if len(w.queues) == 0 {
+		return errors.New("no queues to process tasks from")
+	}
 
-	// Convert task to JSON
-	argsJSON := fmt.Sprintf(`{"Args":{"Message":"%s"}}`, message)
+	// In-memory tasks are only processed when requested by tests.
+	if w.memory != nil {
+		return nil
+	}
 
-	// Execute the query
-	_, err := w.db.ExecContext(ctx, query, ExampleTaskKind, argsJSON, scheduledTime)
+	client, err := w.riverClient()
 	if err != nil {
-		return fmt.Errorf("failed to insert task: %w", err)
+		return err
 	}
 
+	// Advance workflows as their jobs are finalized. The subscription is closed when the client stops.
+	events, _ := client.Subscribe(
+		river.EventKindJobCompleted,
+		river.EventKindJobFailed,
+		river.EventKindJobCancelled,
+	)
+	go w.watchWorkflows(events)
+
+	if err := client.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start River client: %w", err)
+	}
+
+	log.Default().Info("processing tasks",
+		"queues", w.queues,
+	)
+
 	return nil
 }
 
-// MigrateDB runs the River migrations using our migration manager
-func MigrateDB(ctx context.Context, db *sql.DB) error {
-	// Import the migrations package
-	migrationManager := migrations.NewRiverManager(db)
-	return migrationManager.ApplyPendingMigrations(ctx)
-}
+// Stop stops fetching new tasks and waits for the running ones to complete
+// If the context expires first, the running tasks are cancelled and given one more
+// ShutdownTimeout to return. Any discard hooks which are still running are then waited for.
+func (w *Worker) Stop(ctx context.Context) error {
+	if w.memory != nil {
+		return nil
+	}
+
+	// Nothing to stop if the worker was never used.
+	w.clientMu.Lock()
+	client := w.client
+	w.clientMu.Unlock()
+	if client == nil {
+		return nil
+	}
+
+	err := client.Stop(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Default().Warn("tasks did not complete in time, cancelling them")
+
+		cancelCtx, cancel := context.WithTimeout(context.Background(), w.config.ShutdownTimeout)
+		defer cancel()
+		ctx = cancelCtx
+		err = client.StopAndCancel(ctx)
+	}
 
-// CreateMigrationSQL returns the SQL needed to create the River tables
-func CreateMigrationSQL() string {
-	return `
--- River Queue Schema
-
--- Create extension for UUID generation if not exists
-CREATE EXTENSION IF NOT EXISTS pgcrypto;
-
--- river_jobs table stores all queued, running, and completed jobs
-CREATE TABLE IF NOT EXISTS river_jobs (
-    id UUID PRIMARY KEY,
-    args JSONB NOT NULL,
-    attempt INT NOT NULL DEFAULT 0,
-    attempt_total INT NOT NULL DEFAULT 0,
-    attempted_at TIMESTAMPTZ,
-    cancelled_at TIMESTAMPTZ,
-    completed_at TIMESTAMPTZ,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    discard_reason TEXT,
-    error JSONB,
-    finalizer_priority INT,
-    finalizer_retry_at TIMESTAMPTZ,
-    finalizer_total_attempt_limit INT,
-    finalized_at TIMESTAMPTZ,
-    kind TEXT NOT NULL,
-    max_attempts INT NOT NULL DEFAULT 25,
-    metadata JSONB,
-    priority INT NOT NULL DEFAULT 0,
-    queue TEXT NOT NULL DEFAULT 'default',
-    scheduled_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    state TEXT NOT NULL DEFAULT 'available',
-    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    worker_id TEXT
-);
-
--- Create indexes needed for efficient operations
-CREATE INDEX IF NOT EXISTS river_jobs_kind_idx ON river_jobs (kind);
-CREATE INDEX IF NOT EXISTS river_jobs_queue_idx ON river_jobs (queue);
-CREATE INDEX IF NOT EXISTS river_jobs_scheduled_at_idx ON river_jobs (scheduled_at);
-CREATE INDEX IF NOT EXISTS river_jobs_state_idx ON river_jobs (state);
-CREATE INDEX IF NOT EXISTS river_jobs_updated_at_idx ON river_jobs (updated_at);
-
--- River client info table stores information about River clients
-CREATE TABLE IF NOT EXISTS river_client_info (
-    id TEXT PRIMARY KEY,
-    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
-
--- River Leader clock keeps track of leadership
-CREATE TABLE IF NOT EXISTS river_leader_clock (
-    id INT PRIMARY KEY,
-    timestamp TIMESTAMPTZ NOT NULL
-);
-
--- River job period tracks periodic jobs and their last execution
-CREATE TABLE IF NOT EXISTS river_job_periodic (
-    id TEXT PRIMARY KEY,
-    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    args JSONB NOT NULL,
-    inserted_job_id UUID,
-    interval_ms BIGINT NOT NULL,
-    kind TEXT NOT NULL,
-    last_inserted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    state TEXT NOT NULL DEFAULT 'enabled'
-);
-`
+	// Discard hooks run in the background, so they may still be running once the tasks have stopped.
+	return errors.Join(err, w.waitForHooks(ctx))
 }
 
-// RunMigrations runs the River migrations directly using SQL
-func RunMigrations(db *sql.DB) error {
-	sql := CreateMigrationSQL()
-	_, err := db.Exec(sql)
+// Register registers the handler that processes tasks of a given type
+// The handler is wrapped by the middleware added with Use(). An error is returned if a handler is already
+// registered for the task kind. If the handler returns an error the task is retried, according to the kind's
+// RetryPolicy if one is provided, until it runs out of attempts and is discarded.
+func Register[T TaskArgs](w *Worker, handler func(ctx context.Context, task T) error, opts ...RegisterOption) error {
+	process := func(ctx context.Context, job *RunningJob, task T) error {
+		return w.chain(func(ctx context.Context) error {
+			return handler(ctx, task)
+		})(withJob(ctx, w, job))
+	}
+
+	err := river.AddWorkerSafely(w.workers, river.WorkFunc(func(ctx context.Context, job *river.Job[T]) error {
+		return process(ctx, newRunningJob(job.JobRow), job.Args)
+	}))
 	if err != nil {
-		return fmt.Errorf("failed to execute migration SQL: %w", err)
+		return err
+	}
+
+	var task T
+	k := &kindOptions{
+		run: func(ctx context.Context, job *RunningJob, args []byte) error {
+			var task T
+			if err := json.Unmarshal(args, &task); err != nil {
+				return fmt.Errorf("failed to decode task: %w", err)
+			}
+			return process(ctx, job, task)
+		},
+	}
+	for _, opt := range opts {
+		opt(k)
 	}
+	w.kinds[task.Kind()] = k
 
-	log.Println("River migrations executed successfully")
 	return nil
 }
+
+// Insert inserts a new task to be processed by the handler registered for its kind
+func Insert[T TaskArgs](ctx context.Context, w *Worker, task T, opts ...InsertOption) (*InsertResult, error) {
+	insertOpts, err := buildInsertOpts(w.insertOptions(ctx, task.Kind(), opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if w.memory != nil {
+		return w.memory.insert(task, insertOpts)
+	}
+
+	client, err := w.riverClient()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Insert(ctx, task, insertOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert task: %w", err)
+	}
+
+	return &InsertResult{
+		ID:        res.Job.ID,
+		Duplicate: res.UniqueSkippedAsDuplicate,
+	}, nil
+}
+
+// InsertTx inserts a new task within a database transaction
+// The task is only visible to workers once the transaction commits, and is discarded if it rolls back, so it
+// can be inserted atomically alongside the changes it depends on. See services.WithTx().
+// When running tests the task is stored in memory immediately, regardless of the transaction.
+func InsertTx[T TaskArgs](ctx context.Context, w *Worker, tx *sql.Tx, task T, opts ...InsertOption) (*InsertResult, error) {
+	insertOpts, err := buildInsertOpts(w.insertOptions(ctx, task.Kind(), opts))
+	if err != nil {
+		return nil, err
+	}
+
+	if w.memory != nil {
+		return w.memory.insert(task, insertOpts)
+	}
+
+	client, err := w.riverClient()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.InsertTx(ctx, tx, task, insertOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert task: %w", err)
+	}
+
+	return &InsertResult{
+		ID:        res.Job.ID,
+		Duplicate: res.UniqueSkippedAsDuplicate,
+	}, nil
+}
+
+// MigrateDB runs the River migrations using our migration manager
+func MigrateDB(ctx context.Context, db *sql.DB) error {
+	migrationManager := migrations.NewRiverManager(db)
+	return migrationManager.ApplyPendingMigrations(ctx)
+}
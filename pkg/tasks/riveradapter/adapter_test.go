@@ -0,0 +1,90 @@
+package riveradapter
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_SetQueues(t *testing.T) {
+	w, err := NewWorker(nil, config.TasksConfig{
+		Queues: map[string]int{
+			"default": 2,
+			"mail":    5,
+			"reports": 1,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default", "mail", "reports"}, w.queues)
+
+	require.NoError(t, w.SetQueues("mail", "reports"))
+	assert.Equal(t, []string{"mail", "reports"}, w.queues)
+
+	assert.Error(t, w.SetQueues("missing"))
+	assert.Equal(t, []string{"mail", "reports"}, w.queues)
+}
+
+func TestWorker_SetQueues_AfterUse(t *testing.T) {
+	// Connections are only opened when used, so no database is needed to create the client.
+	db, err := sql.Open("pgx", "postgres://localhost/test")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	w, err := NewWorker(db, config.TasksConfig{
+		Queues: map[string]int{
+			"default": 2,
+			"mail":    5,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.SetQueues("mail"))
+
+	// The client is created once, with the queues selected by then.
+	client, err := w.riverClient()
+	require.NoError(t, err)
+	same, err := w.riverClient()
+	require.NoError(t, err)
+	assert.Same(t, client, same)
+
+	assert.Error(t, w.SetQueues("default"))
+	assert.Equal(t, []string{"mail"}, w.queues)
+}
+
+func TestNewWorker_Driver(t *testing.T) {
+	cfg := config.TasksConfig{Queues: map[string]int{"default": 1}}
+
+	db := sql.OpenDB(otherConnector{})
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err := NewWorker(db, cfg)
+	assert.ErrorContains(t, err, "requires the pgx database driver")
+
+	// Only polling works with any driver.
+	cfg.PollOnly = true
+	_, err = NewWorker(db, cfg)
+	assert.NoError(t, err)
+}
+
+// otherConnector connects through a database driver other than pgx.
+type otherConnector struct{}
+
+func (otherConnector) Connect(context.Context) (sqldriver.Conn, error) {
+	return nil, errors.New("not connectable")
+}
+
+func (otherConnector) Driver() sqldriver.Driver {
+	return otherDriver{}
+}
+
+// otherDriver is a database driver other than pgx.
+type otherDriver struct{}
+
+func (otherDriver) Open(string) (sqldriver.Conn, error) {
+	return nil, errors.New("not connectable")
+}
@@ -0,0 +1,156 @@
+package riveradapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/riverqueue/river/riverdriver"
+	"github.com/riverqueue/river/riverdriver/riverdatabasesql"
+)
+
+type (
+	// driver extends the database/sql River driver with a listener, so that workers are woken by the
+	// NOTIFY issued when tasks are inserted rather than only finding them when polling.
+	driver struct {
+		*riverdatabasesql.Driver
+		db *sql.DB
+	}
+
+	// listener receives notifications on a connection taken out of the shared pool for as long as it is
+	// connected. This requires the connection to use the pgx driver.
+	listener struct {
+		db      *sql.DB
+		sqlConn *sql.Conn
+		conn    *pgx.Conn
+		prefix  string
+		mu      sync.Mutex
+	}
+)
+
+// newDriver creates a River driver which operates on the shared database connection.
+func newDriver(db *sql.DB) *driver {
+	return &driver{
+		Driver: riverdatabasesql.New(db),
+		db:     db,
+	}
+}
+
+// GetListener returns a listener which receives notifications on its own connection from the pool.
+func (d *driver) GetListener() riverdriver.Listener {
+	return &listener{db: d.db}
+}
+
+// SupportsListener reports that notifications are supported, as long as the pgx driver is used.
+func (d *driver) SupportsListener() bool {
+	return true
+}
+
+// Connect takes a connection out of the pool to listen on, returning an error if it does not use the pgx
+// driver or the listener is already connected.
+func (l *listener) Connect(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		return errors.New("connection already established")
+	}
+
+	sqlConn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	var schema string
+	if err := sqlConn.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		_ = sqlConn.Close()
+		return err
+	}
+
+	err = sqlConn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("listening requires the pgx driver, got %T", driverConn)
+		}
+		l.conn = c.Conn()
+		return nil
+	})
+	if err != nil {
+		_ = sqlConn.Close()
+		return err
+	}
+
+	// Notifications are sent to topics prefixed with the schema.
+	l.prefix = schema + "."
+	l.sqlConn = sqlConn
+
+	return nil
+}
+
+// Close closes the connection being listened on, if any.
+func (l *listener) Close(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+
+	// Close the connection rather than returning it to the pool, so no other caller receives a connection
+	// which is still listening. The pool discards it once it is released.
+	err := l.conn.Close(ctx)
+	_ = l.sqlConn.Close()
+
+	l.conn = nil
+	l.sqlConn = nil
+
+	return err
+}
+
+// Listen starts listening for notifications on the topic, within the current schema.
+func (l *listener) Listen(ctx context.Context, topic string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := l.conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.prefix + topic}.Sanitize())
+	return err
+}
+
+// Unlisten stops listening for notifications on the topic.
+func (l *listener) Unlisten(ctx context.Context, topic string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := l.conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{l.prefix + topic}.Sanitize())
+	return err
+}
+
+// Ping checks that the connection being listened on is still alive.
+func (l *listener) Ping(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.conn.Ping(ctx)
+}
+
+// WaitForNotification blocks until a notification is received on one of the topics being listened on, or
+// the context is done. The schema prefix is removed from the topic of the notification.
+func (l *listener) WaitForNotification(ctx context.Context) (*riverdriver.Notification, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n, err := l.conn.WaitForNotification(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &riverdriver.Notification{
+		Topic:   strings.TrimPrefix(n.Channel, l.prefix),
+		Payload: n.Payload,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package riveradapter
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriver_Listener(t *testing.T) {
+	d := newDriver(nil)
+	assert.True(t, d.SupportsListener())
+	assert.IsType(t, &listener{}, d.GetListener())
+}
+
+func TestListener_WaitForNotification(t *testing.T) {
+	db := openTestDB(t)
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+
+	l := newDriver(db).GetListener()
+	require.NoError(t, l.Connect(ctx))
+	t.Cleanup(func() { _ = l.Close(context.Background()) })
+	require.NoError(t, l.Listen(ctx, "river_insert"))
+
+	var schema string
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema))
+
+	// Only notifications on the topic prefixed with the schema are received, with the prefix removed.
+	_, err := db.ExecContext(ctx, "SELECT pg_notify($1, $2)", "river_insert", `{"queue":"unprefixed"}`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "SELECT pg_notify($1, $2)", schema+".river_insert", `{"queue":"default"}`)
+	require.NoError(t, err)
+
+	n, err := l.WaitForNotification(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "river_insert", n.Topic)
+	assert.JSONEq(t, `{"queue":"default"}`, n.Payload)
+
+	require.NoError(t, l.Unlisten(ctx, "river_insert"))
+}
+
+// openTestDB connects to the database set by DB_TEST_CONNECTION, or DATABASE_URL as with the config, skipping
+// the test if it is not available.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	connection := os.Getenv("DB_TEST_CONNECTION")
+	if connection == "" {
+		connection = os.Getenv("DATABASE_URL")
+	}
+	if connection == "" {
+		t.Skip("neither DB_TEST_CONNECTION nor DATABASE_URL is set")
+	}
+
+	db, err := sql.Open("pgx", connection)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		t.Skipf("test database is not available: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,526 @@
+package riveradapter
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// Memory stores tasks in memory rather than in the database, so that code which inserts tasks can be tested
+// without Postgres. Tasks are only processed when a test calls Run() or Drain().
+type Memory struct {
+	worker *Worker
+	mu     sync.Mutex
+	nextID int64
+	jobs   []*Job
+}
+
+// NewMemoryWorker creates a new Worker which stores tasks in memory, for use in tests
+// It supports the same operations as a worker created with NewWorker(), except that periodic tasks are never
+// inserted and tasks inserted within a transaction are stored immediately. Use Memory() to inspect and process
+// the tasks.
+func NewMemoryWorker(cfg config.TasksConfig) *Worker {
+	w := &Worker{
+		config:  cfg,
+		workers: river.NewWorkers(),
+		kinds:   make(map[string]*kindOptions),
+		queues:  slices.Sorted(maps.Keys(cfg.Queues)),
+	}
+	w.memory = &Memory{worker: w}
+	return w
+}
+
+// Memory returns the in-memory task storage, or nil if the worker was not created with NewMemoryWorker().
+func (w *Worker) Memory() *Memory {
+	return w.memory
+}
+
+// Enqueued returns the tasks of type T which are waiting to be processed, in the order they were inserted.
+func Enqueued[T TaskArgs](m *Memory) []T {
+	var (
+		kind  T
+		tasks []T
+	)
+
+	for _, j := range m.Jobs() {
+		if j.Kind != kind.Kind() || j.IsFinalized() || j.State == JobStateRunning {
+			continue
+		}
+
+		var task T
+		if err := json.Unmarshal([]byte(j.Args), &task); err == nil {
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks
+}
+
+// Jobs returns all stored jobs, in the order they were inserted.
+func (m *Memory) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, cloneJob(j))
+	}
+	return jobs
+}
+
+// Run processes a job synchronously, regardless of when it is scheduled, and returns the error returned by
+// its handler. Only jobs waiting to be processed can be run, so an error is returned for any other state.
+// The job is then updated as it would be by River, so failed jobs are retried or discarded according to the
+// retry policy of their kind.
+func (m *Memory) Run(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	j, err := m.find(id)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+
+	if !j.waiting() {
+		m.mu.Unlock()
+		return fmt.Errorf("job %d cannot be run while %s", id, j.State)
+	}
+
+	k, ok := m.worker.kinds[j.Kind]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("no handler registered for task kind %q", j.Kind)
+	}
+
+	now := time.Now()
+	j.State = JobStateRunning
+	j.Attempt++
+	j.AttemptedAt = &now
+	job := &RunningJob{
+		ID:          j.ID,
+		Kind:        j.Kind,
+		Queue:       j.Queue,
+		Attempt:     j.Attempt,
+		MaxAttempts: j.MaxAttempts,
+		Metadata:    j.Metadata,
+	}
+	args := []byte(j.Args)
+	m.mu.Unlock()
+
+	// The lock is not held while processing, so the handler can insert tasks and report progress.
+	err = k.run(ctx, job, args)
+	m.finish(ctx, j, err)
+
+	if meta := jobWorkflow(job.Metadata); meta != nil {
+		m.advanceWorkflow(meta.ID)
+	}
+
+	return err
+}
+
+// finish updates a job once its handler has returned.
+func (m *Memory) finish(ctx context.Context, j *Job, err error) {
+	if err == nil {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		now := time.Now()
+		j.State = JobStateCompleted
+		j.FinalizedAt = &now
+		return
+	}
+
+	m.mu.Lock()
+	row := jobRow(j)
+	m.mu.Unlock()
+
+	// Discard hooks run in the background, as with River, but are waited for so tests can check their effects.
+	(&errorHandler{worker: m.worker}).HandleError(ctx, row, err)
+	m.worker.hooks.Wait()
+	next := (&retryPolicy{worker: m.worker, fallback: &river.DefaultClientRetryPolicy{}}).NextRetry(row)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	j.Errors = append(j.Errors, JobError{
+		At:      now,
+		Attempt: j.Attempt,
+		Error:   err.Error(),
+	})
+
+	// As with River, the error handler can shorten the attempts of the row.
+	if row.Attempt >= row.MaxAttempts {
+		j.State = JobStateDiscarded
+		j.FinalizedAt = &now
+	} else {
+		j.State = JobStateRetryable
+		j.ScheduledAt = next
+	}
+}
+
+// Drain runs every job waiting to be processed, including those inserted while draining, regardless of when
+// they are scheduled. Each job is attempted at most once per call, so jobs which fail are left to be retried
+// by a later call. The errors returned by the handlers are joined.
+func (m *Memory) Drain(ctx context.Context) error {
+	var (
+		attempted = make(map[int64]bool)
+		errs      []error
+	)
+
+	for {
+		id, ok := m.next(attempted)
+		if !ok {
+			return errors.Join(errs...)
+		}
+
+		attempted[id] = true
+		if err := m.Run(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("job %d: %w", id, err))
+		}
+	}
+}
+
+// Reset removes all stored jobs.
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs = nil
+}
+
+// next returns the ID of the job waiting to be processed, which has not yet been attempted, that River would
+// process first.
+func (m *Memory) next(attempted map[int64]bool) (int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var next *Job
+	for _, j := range m.jobs {
+		switch {
+		case attempted[j.ID]:
+		case !j.waiting():
+		case next == nil || j.Priority < next.Priority:
+			next = j
+		}
+	}
+
+	if next == nil {
+		return 0, false
+	}
+	return next.ID, true
+}
+
+// waiting returns true if the job is waiting to be processed.
+func (j *Job) waiting() bool {
+	return j.State == JobStateAvailable || j.State == JobStateScheduled || j.State == JobStateRetryable
+}
+
+// insert stores a new job, unless it is a duplicate according to the unique options.
+func (m *Memory) insert(task TaskArgs, opts *river.InsertOpts) (*InsertResult, error) {
+	args, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode task: %w", err)
+	}
+
+	now := time.Now()
+	j := &Job{
+		Kind:        task.Kind(),
+		Queue:       cmp.Or(opts.Queue, river.QueueDefault),
+		State:       JobStateAvailable,
+		Priority:    cmp.Or(opts.Priority, river.PriorityDefault),
+		MaxAttempts: cmp.Or(opts.MaxAttempts, river.MaxAttemptsDefault),
+		Args:        string(args),
+		Metadata:    cmp.Or(string(opts.Metadata), "{}"),
+		CreatedAt:   now,
+		ScheduledAt: now,
+	}
+
+	switch {
+	case opts.Pending:
+		j.State = JobStatePending
+	case opts.ScheduledAt.After(now):
+		j.State = JobStateScheduled
+		j.ScheduledAt = opts.ScheduledAt
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if dup := m.duplicate(j, opts.UniqueOpts); dup != nil {
+		return &InsertResult{ID: dup.ID, Duplicate: true}, nil
+	}
+
+	m.nextID++
+	j.ID = m.nextID
+	m.jobs = append(m.jobs, j)
+
+	return &InsertResult{ID: j.ID}, nil
+}
+
+// duplicate returns the stored job which the given job is a duplicate of, according to the unique options.
+func (m *Memory) duplicate(j *Job, unique river.UniqueOpts) *Job {
+	if !isUnique(unique) {
+		return nil
+	}
+
+	states := unique.ByState
+	if states == nil {
+		states = rivertype.UniqueOptsByStateDefault()
+	}
+
+	for _, e := range m.jobs {
+		switch {
+		case !unique.ExcludeKind && e.Kind != j.Kind,
+			unique.ByArgs && e.Args != j.Args,
+			unique.ByQueue && e.Queue != j.Queue,
+			unique.ByPeriod > 0 && !e.CreatedAt.Truncate(unique.ByPeriod).Equal(j.CreatedAt.Truncate(unique.ByPeriod)),
+			!slices.Contains(states, rivertype.JobState(e.State)):
+			continue
+		}
+		return e
+	}
+
+	return nil
+}
+
+// find returns the stored job with the given ID. The lock must be held.
+func (m *Memory) find(id int64) (*Job, error) {
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+func (m *Memory) getJob(id int64) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, err := m.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return cloneJob(j), nil
+}
+
+func (m *Memory) listJobs(filter JobFilter, limit, offset int) ([]*Job, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var matches []*Job
+	for _, j := range slices.Backward(m.jobs) {
+		if (filter.State == "" || j.State == filter.State) &&
+			(filter.Kind == "" || j.Kind == filter.Kind) &&
+			(filter.Queue == "" || j.Queue == filter.Queue) {
+			matches = append(matches, cloneJob(j))
+		}
+	}
+
+	total := len(matches)
+	matches = matches[min(offset, total):]
+	return matches[:min(limit, len(matches))], total
+}
+
+func (m *Memory) retryJob(id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, err := m.find(id)
+	if err != nil || j.State == JobStateRunning {
+		return false, err
+	}
+
+	j.State = JobStateAvailable
+	j.ScheduledAt = time.Now()
+	j.FinalizedAt = nil
+	if j.Attempt >= j.MaxAttempts {
+		j.MaxAttempts = j.Attempt + 1
+	}
+	return true, nil
+}
+
+func (m *Memory) cancelJob(id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, err := m.find(id)
+	if err != nil || j.IsFinalized() {
+		return false, err
+	}
+
+	now := time.Now()
+	j.State = JobStateCancelled
+	j.FinalizedAt = &now
+	return true, nil
+}
+
+func (m *Memory) discardJobs(ids []int64) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int
+	for _, id := range ids {
+		j, err := m.find(id)
+		if err != nil || !j.CanDiscard() {
+			continue
+		}
+
+		now := time.Now()
+		j.State = JobStateDiscarded
+		j.FinalizedAt = &now
+		count++
+	}
+	return count
+}
+
+func (m *Memory) queueDepths() []QueueDepth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	depths := make(map[string]*QueueDepth)
+	for _, j := range m.jobs {
+		if j.IsFinalized() {
+			continue
+		}
+
+		d, ok := depths[j.Queue]
+		if !ok {
+			d = &QueueDepth{Queue: j.Queue}
+			depths[j.Queue] = d
+		}
+
+		switch j.State {
+		case JobStateAvailable:
+			d.Available++
+		case JobStateScheduled:
+			d.Scheduled++
+		case JobStateRunning:
+			d.Running++
+		case JobStateRetryable:
+			d.Retryable++
+		case JobStatePending:
+			d.Pending++
+		}
+	}
+
+	list := make([]QueueDepth, 0, len(depths))
+	for _, queue := range slices.Sorted(maps.Keys(depths)) {
+		list = append(list, *depths[queue])
+	}
+	return list
+}
+
+// mergeMetadata merges the JSON-encoded values in to the metadata of a job.
+func (m *Memory) mergeMetadata(id int64, values []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, err := m.find(id)
+	if err != nil {
+		return err
+	}
+
+	var metadata, merge map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(j.Metadata), &metadata); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	if err := json.Unmarshal(values, &merge); err != nil {
+		return fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	if metadata == nil {
+		metadata = merge
+	} else {
+		maps.Copy(metadata, merge)
+	}
+
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	j.Metadata = string(encoded)
+
+	return nil
+}
+
+// workflowJobs returns the jobs of a workflow, ordered by ID.
+func (m *Memory) workflowJobs(id string) []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []*Job
+	for _, j := range m.jobs {
+		if meta := jobWorkflow(j.Metadata); meta != nil && meta.ID == id {
+			jobs = append(jobs, cloneJob(j))
+		}
+	}
+	return jobs
+}
+
+// advanceWorkflow makes the pending jobs of a workflow available once the tasks they depend on have completed,
+// and cancels those which depend on a task that was discarded or cancelled.
+func (m *Memory) advanceWorkflow(id string) {
+	ready, cancelled := resolveWorkflow(m.workflowJobs(id))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range ready {
+		if j, err := m.find(id); err == nil && j.State == JobStatePending {
+			j.State = JobStateAvailable
+			j.ScheduledAt = now
+		}
+	}
+
+	for _, c := range cancelled {
+		if j, err := m.find(c.id); err == nil && j.State == JobStatePending {
+			j.State = JobStateCancelled
+			j.FinalizedAt = &now
+			j.Errors = append(j.Errors, JobError{
+				At:    now,
+				Error: c.reason,
+			})
+		}
+	}
+}
+
+// cloneJob copies a job so that it can be returned without holding the lock.
+func cloneJob(j *Job) *Job {
+	c := *j
+	c.Errors = slices.Clone(j.Errors)
+	return &c
+}
+
+// jobRow converts a job to the row River passes to the retry policy and error handler.
+func jobRow(j *Job) *rivertype.JobRow {
+	row := &rivertype.JobRow{
+		ID:          j.ID,
+		Attempt:     j.Attempt,
+		AttemptedAt: j.AttemptedAt,
+		CreatedAt:   j.CreatedAt,
+		EncodedArgs: []byte(j.Args),
+		FinalizedAt: j.FinalizedAt,
+		Kind:        j.Kind,
+		MaxAttempts: j.MaxAttempts,
+		Metadata:    []byte(j.Metadata),
+		Priority:    j.Priority,
+		Queue:       j.Queue,
+		ScheduledAt: j.ScheduledAt,
+		State:       rivertype.JobState(j.State),
+	}
+	for _, e := range j.Errors {
+		row.Errors = append(row.Errors, rivertype.AttemptError(e))
+	}
+	return row
+}
@@ -0,0 +1,176 @@
+package riveradapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryTestTask struct {
+	Value string `json:"value"`
+}
+
+func (memoryTestTask) Kind() string {
+	return "memory_test"
+}
+
+func newMemoryTestWorker(t *testing.T, handler func(ctx context.Context, task memoryTestTask) error, opts ...RegisterOption) *Worker {
+	w := NewMemoryWorker(config.TasksConfig{
+		Queues: map[string]int{"default": 1},
+	})
+	require.NoError(t, Register(w, handler, opts...))
+	require.NoError(t, w.Start(context.Background()))
+	return w
+}
+
+func TestMemory_Insert(t *testing.T) {
+	w := newMemoryTestWorker(t, func(ctx context.Context, task memoryTestTask) error {
+		return nil
+	})
+	ctx := context.Background()
+
+	res, err := Insert(ctx, w, memoryTestTask{Value: "a"}, Queue("other"), Delay(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, res.Duplicate)
+
+	job, err := w.GetJob(ctx, res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "memory_test", job.Kind)
+	assert.Equal(t, "other", job.Queue)
+	assert.Equal(t, JobStateScheduled, job.State)
+	assert.JSONEq(t, `{"value": "a"}`, job.Args)
+
+	_, err = InsertTx(ctx, w, nil, memoryTestTask{Value: "b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []memoryTestTask{{Value: "a"}, {Value: "b"}}, Enqueued[memoryTestTask](w.Memory()))
+
+	jobs, total, err := w.ListJobs(ctx, JobFilter{Queue: "default"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, jobs, 1)
+	assert.JSONEq(t, `{"value": "b"}`, jobs[0].Args)
+
+	depths, err := w.QueueDepths(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []QueueDepth{
+		{Queue: "default", Available: 1},
+		{Queue: "other", Scheduled: 1},
+	}, depths)
+
+	_, err = w.GetJob(ctx, 100)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestMemory_Unique(t *testing.T) {
+	w := newMemoryTestWorker(t, func(ctx context.Context, task memoryTestTask) error {
+		return nil
+	})
+	ctx := context.Background()
+
+	first, err := Insert(ctx, w, memoryTestTask{Value: "a"}, Unique(UniqueByArgs()))
+	require.NoError(t, err)
+
+	res, err := Insert(ctx, w, memoryTestTask{Value: "a"}, Unique(UniqueByArgs()))
+	require.NoError(t, err)
+	assert.True(t, res.Duplicate)
+	assert.Equal(t, first.ID, res.ID)
+
+	res, err = Insert(ctx, w, memoryTestTask{Value: "b"}, Unique(UniqueByArgs()))
+	require.NoError(t, err)
+	assert.False(t, res.Duplicate)
+
+	res, err = Insert(ctx, w, memoryTestTask{Value: "a"})
+	require.NoError(t, err)
+	assert.False(t, res.Duplicate)
+}
+
+func TestMemory_Drain(t *testing.T) {
+	var (
+		processed []string
+		w         *Worker
+	)
+	w = newMemoryTestWorker(t, func(ctx context.Context, task memoryTestTask) error {
+		processed = append(processed, task.Value)
+
+		switch task.Value {
+		case "fail":
+			return errors.New("failed")
+		case "cancel":
+			return NonRetryable(errors.New("invalid"))
+		case "chain":
+			_, err := Insert(ctx, w, memoryTestTask{Value: "chained"})
+			return err
+		}
+		return ReportProgress(ctx, 100, "done")
+	}, WithRetryPolicy(RetryPolicy{MaxAttempts: 2}))
+	ctx := context.Background()
+	mem := w.Memory()
+
+	for _, value := range []string{"chain", "fail", "cancel"} {
+		_, err := Insert(ctx, w, memoryTestTask{Value: value}, Priority(2))
+		require.NoError(t, err)
+	}
+	_, err := Insert(ctx, w, memoryTestTask{Value: "first"})
+	require.NoError(t, err)
+
+	err = mem.Drain(ctx)
+	require.Error(t, err)
+	assert.Equal(t, []string{"first", "chain", "chained", "fail", "cancel"}, processed)
+
+	states := func() []string {
+		var s []string
+		for _, j := range mem.Jobs() {
+			s = append(s, j.State)
+		}
+		return s
+	}
+	assert.Equal(t, []string{
+		JobStateCompleted,
+		JobStateRetryable,
+		JobStateDiscarded,
+		JobStateCompleted,
+		JobStateCompleted,
+	}, states())
+	assert.Equal(t, []memoryTestTask{{Value: "fail"}}, Enqueued[memoryTestTask](mem))
+
+	job, err := w.GetJob(ctx, 4)
+	require.NoError(t, err)
+	require.NotNil(t, job.Progress())
+	assert.Equal(t, 100, job.Progress().Percent)
+
+	// Failed jobs are retried by the next drain, and discarded once they run out of attempts.
+	processed = nil
+	require.Error(t, mem.Drain(ctx))
+	assert.Equal(t, []string{"fail"}, processed)
+	assert.Equal(t, JobStateDiscarded, mem.Jobs()[1].State)
+	assert.Len(t, mem.Jobs()[1].Errors, 2)
+
+	n, err := w.RetryJobs(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Error(t, mem.Run(ctx, 2))
+	assert.Equal(t, 3, mem.Jobs()[1].Attempt)
+
+	// Finalized jobs are not counted as cancelled.
+	n, err = w.CancelJobs(ctx, 1, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Only jobs waiting to be processed can be run.
+	processed = nil
+	for _, id := range []int64{1, 3, 4} {
+		assert.ErrorContains(t, mem.Run(ctx, id), "cannot be run")
+	}
+	assert.Empty(t, processed)
+	assert.Equal(t, 1, mem.Jobs()[0].Attempt)
+
+	mem.Reset()
+	assert.Empty(t, mem.Jobs())
+	assert.NoError(t, mem.Drain(ctx))
+}
@@ -0,0 +1,187 @@
+package riveradapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// metadataRequestIDKey is the job metadata key that the ID of the HTTP request which inserted the task is
+// stored under.
+const metadataRequestIDKey = "request_id"
+
+type (
+	// HandlerFunc processes the task of the job stored in the context.
+	HandlerFunc func(ctx context.Context) error
+
+	// MiddlewareFunc wraps the processing of tasks, similar to Echo middleware.
+	MiddlewareFunc func(next HandlerFunc) HandlerFunc
+
+	// RunningJob describes the job being processed.
+	RunningJob struct {
+		// ID stores the ID of the job.
+		ID int64
+
+		// Kind stores the kind of the task.
+		Kind string
+
+		// Queue stores the queue the job was fetched from.
+		Queue string
+
+		// Attempt stores the number of the current attempt, starting at 1.
+		Attempt int
+
+		// MaxAttempts stores the maximum number of times the job is attempted.
+		MaxAttempts int
+
+		// Metadata stores the JSON-encoded metadata of the job, as it was when the attempt started.
+		Metadata string
+	}
+)
+
+// newRunningJob creates a RunningJob from a River job row.
+func newRunningJob(row *rivertype.JobRow) *RunningJob {
+	return &RunningJob{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Queue:       row.Queue,
+		Attempt:     row.Attempt,
+		MaxAttempts: row.MaxAttempts,
+		Metadata:    string(row.Metadata),
+	}
+}
+
+// CurrentJob returns the job being processed, if the context belongs to a task handler or middleware.
+func CurrentJob(ctx context.Context) (*RunningJob, bool) {
+	j, ok := ctx.Value(jobContextKey{}).(*jobContext)
+	if !ok {
+		return nil, false
+	}
+	return j.job, true
+}
+
+// Use adds middleware which wraps the processing of every task
+// Middleware is executed in the order provided, so the first wraps all that follow it.
+func (w *Worker) Use(middleware ...MiddlewareFunc) {
+	w.middleware = append(w.middleware, middleware...)
+}
+
+// chain wraps the handler with all middleware.
+func (w *Worker) chain(handler HandlerFunc) HandlerFunc {
+	for i := len(w.middleware) - 1; i >= 0; i-- {
+		handler = w.middleware[i](handler)
+	}
+	return handler
+}
+
+// insertOptions returns the options to insert a task with. Tasks inserted while handling an HTTP request,
+// or while processing a task that was, store the ID of that request in their metadata.
+func (w *Worker) insertOptions(ctx context.Context, kind string, opts []InsertOption) []InsertOption {
+	defaults := w.defaultInsertOptions(kind)
+	if id := log.RequestID(ctx); id != "" {
+		defaults = append(defaults, Metadata(map[string]any{metadataRequestIDKey: id}))
+	}
+	return append(defaults, opts...)
+}
+
+// RequestID restores the ID of the HTTP request that inserted the task, if any, from the job metadata in to
+// the context, so that it is included in logs by Logger() and carried through to tasks inserted by the handler.
+// This must come before Logger().
+func RequestID() MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context) error {
+			if job, ok := CurrentJob(ctx); ok {
+				var metadata struct {
+					RequestID string `json:"request_id"`
+				}
+				if err := json.Unmarshal([]byte(job.Metadata), &metadata); err == nil && metadata.RequestID != "" {
+					ctx = log.WithRequestID(ctx, metadata.RequestID)
+				}
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Logger initializes a logger for the job being processed and stores it in the context, so that all log
+// messages produced while processing it include the job ID, kind and attempt. It then logs the outcome.
+// Handlers can access the logger with log.FromContext().
+func Logger() MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context) error {
+			logger := log.FromContext(ctx)
+			if job, ok := CurrentJob(ctx); ok {
+				logger = logger.With(
+					"job_id", job.ID,
+					"kind", job.Kind,
+					"attempt", job.Attempt,
+				)
+			}
+			if id := log.RequestID(ctx); id != "" {
+				logger = logger.With("request_id", id)
+			}
+			ctx = log.WithContext(ctx, logger)
+
+			// Track how long the task takes to process.
+			start := time.Now()
+			err := next(ctx)
+			sub := logger.With("latency", time.Since(start).String())
+
+			if err != nil {
+				sub.Error("task failed", "error", err)
+			} else {
+				sub.Info("task completed")
+			}
+
+			return err
+		}
+	}
+}
+
+// Recover recovers from panics in the handler and fails the job with an error, so that it is retried like
+// any other failure. The stack trace is logged.
+func Recover() MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.FromContext(ctx).Error("task panicked",
+						"panic", r,
+						"stack", string(debug.Stack()),
+					)
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next(ctx)
+		}
+	}
+}
+
+// Timeout cancels the context of the handler once the timeout of the task kind, set with WithTimeout(),
+// or the given default elapses. Handlers must respect the context for this to take effect.
+// A timeout of zero or less means no limit.
+func Timeout(timeout time.Duration) MiddlewareFunc {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context) error {
+			d := timeout
+			if j, ok := ctx.Value(jobContextKey{}).(*jobContext); ok {
+				if k, ok := j.worker.kinds[j.job.Kind]; ok && k.timeout != 0 {
+					d = k.timeout
+				}
+			}
+
+			if d <= 0 {
+				return next(ctx)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx)
+		}
+	}
+}
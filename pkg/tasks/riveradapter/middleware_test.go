@@ -0,0 +1,140 @@
+package riveradapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker_Use(t *testing.T) {
+	var calls []string
+	mw := func(name string) MiddlewareFunc {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context) error {
+				calls = append(calls, name)
+				return next(ctx)
+			}
+		}
+	}
+
+	w := &Worker{}
+	w.Use(mw("a"), mw("b"))
+	w.Use(mw("c"))
+
+	err := w.chain(func(ctx context.Context) error {
+		calls = append(calls, "handler")
+		return nil
+	})(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "handler"}, calls)
+}
+
+func TestMiddleware_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := log.WithContext(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx = log.WithRequestID(ctx, "req")
+	ctx = withJob(ctx, &Worker{}, &RunningJob{ID: 5, Kind: "test", Attempt: 2})
+
+	var logger *slog.Logger
+	err := Logger()(func(ctx context.Context) error {
+		logger = log.FromContext(ctx)
+		return errors.New("failed")
+	})(ctx)
+	require.Error(t, err)
+	require.NotNil(t, logger)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "task failed", entry["msg"])
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, float64(5), entry["job_id"])
+	assert.Equal(t, "test", entry["kind"])
+	assert.Equal(t, float64(2), entry["attempt"])
+	assert.Equal(t, "req", entry["request_id"])
+	assert.Equal(t, "failed", entry["error"])
+}
+
+func TestMiddleware_Recover(t *testing.T) {
+	err := Recover()(func(ctx context.Context) error {
+		panic("oops")
+	})(context.Background())
+	assert.EqualError(t, err, "panic: oops")
+
+	err = Recover()(func(ctx context.Context) error {
+		return nil
+	})(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestMiddleware_Timeout(t *testing.T) {
+	w := &Worker{
+		kinds: map[string]*kindOptions{
+			"long": {timeout: time.Hour},
+			"none": {timeout: -1},
+		},
+	}
+
+	deadline := func(kind string) (time.Duration, bool) {
+		var (
+			remaining time.Duration
+			ok        bool
+		)
+		ctx := withJob(context.Background(), w, &RunningJob{Kind: kind})
+		err := Timeout(time.Minute)(func(ctx context.Context) error {
+			var d time.Time
+			d, ok = ctx.Deadline()
+			remaining = time.Until(d)
+			return nil
+		})(ctx)
+		require.NoError(t, err)
+		return remaining, ok
+	}
+
+	d, ok := deadline("other")
+	assert.True(t, ok)
+	assert.InDelta(t, time.Minute, d, float64(time.Second))
+
+	d, ok = deadline("long")
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, d, float64(time.Second))
+
+	_, ok = deadline("none")
+	assert.False(t, ok)
+}
+
+func TestMiddleware_RequestID(t *testing.T) {
+	run := func(metadata string) string {
+		var id string
+		ctx := withJob(context.Background(), &Worker{}, &RunningJob{Metadata: metadata})
+		err := RequestID()(func(ctx context.Context) error {
+			id = log.RequestID(ctx)
+			return nil
+		})(ctx)
+		require.NoError(t, err)
+		return id
+	}
+
+	assert.Equal(t, "abc", run(`{"request_id": "abc"}`))
+	assert.Empty(t, run(`{}`))
+}
+
+func TestWorker_InsertOptions(t *testing.T) {
+	w := &Worker{kinds: map[string]*kindOptions{}}
+
+	o, err := buildInsertOpts(w.insertOptions(context.Background(), "test", nil))
+	require.NoError(t, err)
+	assert.Nil(t, o.Metadata)
+
+	ctx := log.WithRequestID(context.Background(), "abc")
+	o, err = buildInsertOpts(w.insertOptions(ctx, "test", []InsertOption{Metadata(map[string]any{"a": 1})}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"request_id": "abc", "a": 1}`, string(o.Metadata))
+}
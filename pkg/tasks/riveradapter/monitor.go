@@ -0,0 +1,416 @@
+package riveradapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/riverqueue/river/rivertype"
+)
+
+// Job states, as stored by River.
+const (
+	JobStateAvailable = string(rivertype.JobStateAvailable)
+	JobStateCancelled = string(rivertype.JobStateCancelled)
+	JobStateCompleted = string(rivertype.JobStateCompleted)
+	JobStateDiscarded = string(rivertype.JobStateDiscarded)
+	JobStatePending   = string(rivertype.JobStatePending)
+	JobStateRetryable = string(rivertype.JobStateRetryable)
+	JobStateRunning   = string(rivertype.JobStateRunning)
+	JobStateScheduled = string(rivertype.JobStateScheduled)
+)
+
+// ErrJobNotFound is returned when a job does not exist.
+var ErrJobNotFound = errors.New("job not found")
+
+type (
+	// Job contains the stored details of an inserted task.
+	Job struct {
+		ID          int64
+		Kind        string
+		Queue       string
+		State       string
+		Priority    int
+		Attempt     int
+		MaxAttempts int
+		Args        string
+		Metadata    string
+		Errors      []JobError
+		CreatedAt   time.Time
+		ScheduledAt time.Time
+		AttemptedAt *time.Time
+		FinalizedAt *time.Time
+	}
+
+	// JobError contains the error returned by a single attempt of a job.
+	JobError struct {
+		At      time.Time `json:"at"`
+		Attempt int       `json:"attempt"`
+		Error   string    `json:"error"`
+		Trace   string    `json:"trace"`
+	}
+
+	// JobFilter filters the jobs returned by ListJobs.
+	// Empty fields do not filter.
+	JobFilter struct {
+		State string
+		Kind  string
+		Queue string
+	}
+
+	// QueueDepth contains the amount of unfinished jobs in a queue, by state.
+	QueueDepth struct {
+		Queue     string
+		Available int
+		Scheduled int
+		Running   int
+		Retryable int
+		Pending   int
+	}
+)
+
+// JobStates returns all the states a job can be in.
+func JobStates() []string {
+	return []string{
+		JobStateAvailable,
+		JobStateScheduled,
+		JobStateRunning,
+		JobStateRetryable,
+		JobStatePending,
+		JobStateCompleted,
+		JobStateCancelled,
+		JobStateDiscarded,
+	}
+}
+
+// IsFinalized returns true if the job will not be attempted again unless it is retried.
+func (j *Job) IsFinalized() bool {
+	switch j.State {
+	case JobStateCompleted, JobStateCancelled, JobStateDiscarded:
+		return true
+	}
+	return false
+}
+
+// CanRetry returns true if the job can be retried.
+func (j *Job) CanRetry() bool {
+	return j.State != JobStateRunning
+}
+
+// CanCancel returns true if the job can be cancelled.
+func (j *Job) CanCancel() bool {
+	return !j.IsFinalized()
+}
+
+// CanDiscard returns true if the job can be discarded.
+func (j *Job) CanDiscard() bool {
+	return !j.IsFinalized() && j.State != JobStateRunning
+}
+
+// jobColumns are the columns selected when loading jobs, in the order scanned by scanJob.
+const jobColumns = `
+	id, kind, queue, state, priority, attempt, max_attempts, args::text, metadata::text,
+	COALESCE(to_jsonb(errors), '[]'::jsonb)::text, created_at, scheduled_at, attempted_at, finalized_at
+`
+
+// scanJob scans a row containing jobColumns into a Job.
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	var (
+		j           Job
+		errs        string
+		attemptedAt sql.NullTime
+		finalizedAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&j.ID,
+		&j.Kind,
+		&j.Queue,
+		&j.State,
+		&j.Priority,
+		&j.Attempt,
+		&j.MaxAttempts,
+		&j.Args,
+		&j.Metadata,
+		&errs,
+		&j.CreatedAt,
+		&j.ScheduledAt,
+		&attemptedAt,
+		&finalizedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(errs), &j.Errors); err != nil {
+		return nil, fmt.Errorf("failed to decode errors of job %d: %w", j.ID, err)
+	}
+	if attemptedAt.Valid {
+		j.AttemptedAt = &attemptedAt.Time
+	}
+	if finalizedAt.Valid {
+		j.FinalizedAt = &finalizedAt.Time
+	}
+
+	return &j, nil
+}
+
+// where returns the SQL conditions and arguments for the filter.
+func (f JobFilter) where() (string, []any) {
+	var (
+		conditions = []string{"TRUE"}
+		args       []any
+	)
+
+	add := func(column, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	add("state", f.State)
+	add("kind", f.Kind)
+	add("queue", f.Queue)
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// ListJobs returns the jobs matching the filter, most recently created first, along with the total amount
+// of jobs which match.
+func (w *Worker) ListJobs(ctx context.Context, filter JobFilter, limit, offset int) ([]*Job, int, error) {
+	if w.memory != nil {
+		jobs, total := w.memory.listJobs(filter, limit, offset)
+		return jobs, total, nil
+	}
+
+	where, args := filter.where()
+
+	var total int
+	err := w.db.QueryRowContext(ctx, "SELECT count(*) FROM river_job WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM river_job WHERE %s ORDER BY id DESC LIMIT $%d OFFSET $%d",
+		jobColumns, where, len(args)+1, len(args)+2,
+	)
+	rows, err := w.db.QueryContext(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to query jobs: %w", err)
+	}
+
+	return jobs, total, nil
+}
+
+// GetJob returns a single job.
+// ErrJobNotFound is returned if the job does not exist.
+func (w *Worker) GetJob(ctx context.Context, id int64) (*Job, error) {
+	if w.memory != nil {
+		return w.memory.getJob(id)
+	}
+
+	row := w.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM river_job WHERE id = $1", id)
+
+	j, err := scanJob(row)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrJobNotFound
+	case err != nil:
+		return nil, fmt.Errorf("failed to query job: %w", err)
+	}
+
+	return j, nil
+}
+
+// RetryJobs schedules jobs to be attempted again as soon as possible, even if they were already
+// completed, cancelled or discarded, and returns the amount retried. Running jobs are not affected.
+func (w *Worker) RetryJobs(ctx context.Context, ids ...int64) (int, error) {
+	var (
+		n    int
+		errs []error
+	)
+	for _, id := range ids {
+		if w.memory != nil {
+			retried, err := w.memory.retryJob(id)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to retry job %d: %w", id, err))
+			}
+			if retried {
+				n++
+			}
+			continue
+		}
+
+		client, err := w.riverClient()
+		if err != nil {
+			return n, err
+		}
+
+		// River returns running jobs unchanged.
+		job, err := client.JobRetry(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to retry job %d: %w", id, mapJobError(err)))
+			continue
+		}
+		if job.State != rivertype.JobStateRunning {
+			n++
+		}
+	}
+	return n, errors.Join(errs...)
+}
+
+// CancelJobs cancels jobs which have not yet been finalized, and returns the amount cancelled.
+// A running job has its context cancelled, and is marked as cancelled once its handler returns.
+func (w *Worker) CancelJobs(ctx context.Context, ids ...int64) (int, error) {
+	var (
+		n    int
+		errs []error
+	)
+	for _, id := range ids {
+		if w.memory != nil {
+			cancelled, err := w.memory.cancelJob(id)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to cancel job %d: %w", id, err))
+			}
+			if cancelled {
+				n++
+			}
+			continue
+		}
+
+		client, err := w.riverClient()
+		if err != nil {
+			return n, err
+		}
+
+		// River returns finalized jobs unchanged, so they are skipped to tell them apart from those it cancels.
+		job, err := client.JobGet(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to cancel job %d: %w", id, mapJobError(err)))
+			continue
+		}
+		if job.FinalizedAt != nil {
+			continue
+		}
+
+		job, err = client.JobCancel(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to cancel job %d: %w", id, mapJobError(err)))
+			continue
+		}
+		if job.State == rivertype.JobStateCancelled || job.State == rivertype.JobStateRunning {
+			n++
+		}
+	}
+
+	// Cancel the tasks which depend on any that were waiting to run. Running jobs are handled once finalized.
+	errs = append(errs, w.advanceWorkflowsOf(ctx, ids))
+
+	return n, errors.Join(errs...)
+}
+
+// DiscardJobs discards jobs which are waiting to be run so that they are never attempted again.
+// Unlike cancelling, the job is marked as having failed. Running and finalized jobs are not affected.
+func (w *Worker) DiscardJobs(ctx context.Context, ids ...int64) (int, error) {
+	var n int
+	if w.memory != nil {
+		n = w.memory.discardJobs(ids)
+	} else {
+		res, err := w.db.ExecContext(ctx, `
+			UPDATE river_job
+			SET state = 'discarded', finalized_at = now()
+			WHERE id = ANY($1) AND state IN ('available', 'pending', 'retryable', 'scheduled')
+		`, ids)
+		if err != nil {
+			return 0, fmt.Errorf("failed to discard jobs: %w", err)
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		n = int(affected)
+	}
+
+	// Cancel the tasks which depend on those discarded.
+	return n, w.advanceWorkflowsOf(ctx, ids)
+}
+
+// QueueDepths returns the amount of unfinished jobs in each queue which has any.
+func (w *Worker) QueueDepths(ctx context.Context) ([]QueueDepth, error) {
+	if w.memory != nil {
+		return w.memory.queueDepths(), nil
+	}
+
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT queue, state, count(*)
+		FROM river_job
+		WHERE state IN ('available', 'scheduled', 'running', 'retryable', 'pending')
+		GROUP BY queue, state
+		ORDER BY queue
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query queue depths: %w", err)
+	}
+	defer rows.Close()
+
+	var depths []QueueDepth
+	for rows.Next() {
+		var (
+			queue, state string
+			count        int
+		)
+		if err := rows.Scan(&queue, &state, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan queue depth: %w", err)
+		}
+
+		if len(depths) == 0 || depths[len(depths)-1].Queue != queue {
+			depths = append(depths, QueueDepth{Queue: queue})
+		}
+		d := &depths[len(depths)-1]
+
+		switch state {
+		case JobStateAvailable:
+			d.Available = count
+		case JobStateScheduled:
+			d.Scheduled = count
+		case JobStateRunning:
+			d.Running = count
+		case JobStateRetryable:
+			d.Retryable = count
+		case JobStatePending:
+			d.Pending = count
+		}
+	}
+
+	return depths, rows.Err()
+}
+
+// mapJobError converts errors returned by River for a single job.
+func mapJobError(err error) error {
+	if errors.Is(err, rivertype.ErrNotFound) {
+		return ErrJobNotFound
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package riveradapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobFilter_Where(t *testing.T) {
+	where, args := JobFilter{}.where()
+	assert.Equal(t, "TRUE", where)
+	assert.Empty(t, args)
+
+	where, args = JobFilter{State: JobStateRetryable, Queue: "mail"}.where()
+	assert.Equal(t, "TRUE AND state = $1 AND queue = $2", where)
+	assert.Equal(t, []any{JobStateRetryable, "mail"}, args)
+
+	where, args = JobFilter{State: JobStateDiscarded, Kind: "example_task", Queue: "default"}.where()
+	assert.Equal(t, "TRUE AND state = $1 AND kind = $2 AND queue = $3", where)
+	assert.Equal(t, []any{JobStateDiscarded, "example_task", "default"}, args)
+}
+
+func TestJob_Actions(t *testing.T) {
+	tests := map[string]struct {
+		retry, cancel, discard bool
+	}{
+		JobStateAvailable: {retry: true, cancel: true, discard: true},
+		JobStateScheduled: {retry: true, cancel: true, discard: true},
+		JobStateRetryable: {retry: true, cancel: true, discard: true},
+		JobStatePending:   {retry: true, cancel: true, discard: true},
+		JobStateRunning:   {retry: false, cancel: true, discard: false},
+		JobStateCompleted: {retry: true, cancel: false, discard: false},
+		JobStateCancelled: {retry: true, cancel: false, discard: false},
+		JobStateDiscarded: {retry: true, cancel: false, discard: false},
+	}
+
+	for state, test := range tests {
+		t.Run(state, func(t *testing.T) {
+			j := Job{State: state}
+			assert.Equal(t, test.retry, j.CanRetry())
+			assert.Equal(t, test.cancel, j.CanCancel())
+			assert.Equal(t, test.discard, j.CanDiscard())
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package riveradapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+type (
+	// InsertOption configures how a task is inserted.
+	InsertOption func(*insertOptions)
+
+	// UniqueOption configures which tasks are considered duplicates of each other.
+	UniqueOption func(*river.UniqueOpts)
+
+	// insertOptions collects the options provided when inserting a task.
+	insertOptions struct {
+		river    river.InsertOpts
+		metadata map[string]any
+	}
+)
+
+// Queue sets the name of the queue the task is inserted in to.
+func Queue(name string) InsertOption {
+	return func(o *insertOptions) {
+		o.river.Queue = name
+	}
+}
+
+// Priority sets the priority of the task, from 1 (highest) to 4 (lowest).
+func Priority(priority int) InsertOption {
+	return func(o *insertOptions) {
+		o.river.Priority = priority
+	}
+}
+
+// Delay sets how long to wait before the task is executed.
+func Delay(delay time.Duration) InsertOption {
+	return func(o *insertOptions) {
+		o.river.ScheduledAt = time.Now().Add(delay)
+	}
+}
+
+// MaxAttempts sets the maximum number of times the task will be attempted before it is discarded.
+func MaxAttempts(attempts int) InsertOption {
+	return func(o *insertOptions) {
+		o.river.MaxAttempts = attempts
+	}
+}
+
+// Metadata adds values to the metadata stored alongside the task.
+// This can be provided more than once, in which case the values are merged.
+func Metadata(values map[string]any) InsertOption {
+	return func(o *insertOptions) {
+		if o.metadata == nil {
+			o.metadata = make(map[string]any, len(values))
+		}
+		for k, v := range values {
+			o.metadata[k] = v
+		}
+	}
+}
+
+// Unique prevents the task from being inserted if a duplicate already exists, in which case the insert
+// succeeds and InsertResult.Duplicate is set rather than a new task being created.
+// By default, any other task of the same kind is a duplicate unless it was cancelled or discarded; the
+// options narrow that down to the same args, queue or time period, or widen it to all kinds.
+func Unique(opts ...UniqueOption) InsertOption {
+	return func(o *insertOptions) {
+		u := river.UniqueOpts{}
+		for _, opt := range opts {
+			opt(&u)
+		}
+
+		// Without any other property set River does not enforce uniqueness at all.
+		if u.ByState == nil {
+			u.ByState = rivertype.UniqueOptsByStateDefault()
+		}
+
+		o.river.UniqueOpts = u
+	}
+}
+
+// UniqueByArgs only considers tasks with the same args to be duplicates.
+// All args are compared, unless some fields are tagged with `river:"unique"` in which case only those are.
+func UniqueByArgs() UniqueOption {
+	return func(u *river.UniqueOpts) {
+		u.ByArgs = true
+	}
+}
+
+// UniqueByQueue only considers tasks in the same queue to be duplicates.
+func UniqueByQueue() UniqueOption {
+	return func(u *river.UniqueOpts) {
+		u.ByQueue = true
+	}
+}
+
+// UniqueByPeriod only considers tasks inserted within the same period to be duplicates.
+// Time is divided into fixed windows of the given length, rather than a window starting at each insert.
+// The period must be at least one second.
+func UniqueByPeriod(period time.Duration) UniqueOption {
+	return func(u *river.UniqueOpts) {
+		u.ByPeriod = period
+	}
+}
+
+// UniqueAcrossKinds considers tasks of any kind to be duplicates, rather than only tasks of the same kind.
+func UniqueAcrossKinds() UniqueOption {
+	return func(u *river.UniqueOpts) {
+		u.ExcludeKind = true
+	}
+}
+
+// UniqueWhileUnfinished only considers tasks which are waiting to be run, running or due to be retried to be
+// duplicates, so the same task can be inserted again as soon as the previous one completes.
+func UniqueWhileUnfinished() UniqueOption {
+	return func(u *river.UniqueOpts) {
+		u.ByState = []rivertype.JobState{
+			rivertype.JobStateAvailable,
+			rivertype.JobStatePending,
+			rivertype.JobStateRetryable,
+			rivertype.JobStateRunning,
+			rivertype.JobStateScheduled,
+		}
+	}
+}
+
+// buildInsertOpts applies the given options and returns the equivalent River insert options.
+func buildInsertOpts(opts []InsertOption) (*river.InsertOpts, error) {
+	var o insertOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.metadata != nil {
+		metadata, err := json.Marshal(o.metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode task metadata: %w", err)
+		}
+		o.river.Metadata = metadata
+	}
+
+	return &o.river, nil
+}
+
+// isUnique returns true if the options prevent duplicate tasks from being inserted.
+func isUnique(opts river.UniqueOpts) bool {
+	return opts.ByArgs || opts.ByPeriod > 0 || opts.ByQueue || opts.ByState != nil
+}
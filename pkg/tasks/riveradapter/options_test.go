@@ -0,0 +1,57 @@
+package riveradapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/riverqueue/river/rivertype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInsertOpts(t *testing.T) {
+	opts, err := buildInsertOpts(nil)
+	require.NoError(t, err)
+	assert.Empty(t, opts.Queue)
+	assert.Nil(t, opts.Metadata)
+
+	now := time.Now()
+	opts, err = buildInsertOpts([]InsertOption{
+		Queue("mail"),
+		Priority(2),
+		Delay(time.Minute),
+		MaxAttempts(3),
+		Metadata(map[string]any{"a": 1}),
+		Metadata(map[string]any{"b": `"quoted"`}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "mail", opts.Queue)
+	assert.Equal(t, 2, opts.Priority)
+	assert.Equal(t, 3, opts.MaxAttempts)
+	assert.WithinDuration(t, now.Add(time.Minute), opts.ScheduledAt, time.Second)
+	assert.JSONEq(t, `{"a":1,"b":"\"quoted\""}`, string(opts.Metadata))
+}
+
+func TestUnique(t *testing.T) {
+	opts, err := buildInsertOpts([]InsertOption{Unique()})
+	require.NoError(t, err)
+	assert.False(t, opts.UniqueOpts.ByArgs)
+	assert.Equal(t, rivertype.UniqueOptsByStateDefault(), opts.UniqueOpts.ByState)
+
+	opts, err = buildInsertOpts([]InsertOption{
+		Unique(
+			UniqueByArgs(),
+			UniqueByQueue(),
+			UniqueByPeriod(time.Hour),
+			UniqueAcrossKinds(),
+			UniqueWhileUnfinished(),
+		),
+	})
+	require.NoError(t, err)
+	assert.True(t, opts.UniqueOpts.ByArgs)
+	assert.True(t, opts.UniqueOpts.ByQueue)
+	assert.True(t, opts.UniqueOpts.ExcludeKind)
+	assert.Equal(t, time.Hour, opts.UniqueOpts.ByPeriod)
+	assert.NotContains(t, opts.UniqueOpts.ByState, rivertype.JobStateCompleted)
+	assert.Contains(t, opts.UniqueOpts.ByState, rivertype.JobStateRunning)
+}
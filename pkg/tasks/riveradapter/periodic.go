@@ -0,0 +1,253 @@
+package riveradapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/riverqueue/river"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// periodicStateEnabled is the state of a periodic task which should be inserted when due.
+	// Setting any other state in river_job_periodic pauses the periodic task.
+	periodicStateEnabled = "enabled"
+
+	// periodicSlack is how early a periodic task may be inserted compared to when it is due.
+	periodicSlack = time.Second
+
+	// periodicClaimTimeout is the maximum amount of time to wait when recording a periodic insert.
+	periodicClaimTimeout = 10 * time.Second
+
+	// periodicSkippedMessage is the message River logs each time a periodic job constructor returns nil.
+	periodicSkippedMessage = "nil returned from periodic job constructor, skipping"
+)
+
+// Schedule determines when a periodic task should next be inserted.
+type Schedule interface {
+	// Next returns the next time the task should be inserted after the given time.
+	Next(time.Time) time.Time
+}
+
+// intervalSchedule is a schedule that repeats at a fixed interval.
+type intervalSchedule time.Duration
+
+// Next returns the next time the task should be inserted after the given time.
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// Every returns a schedule that inserts a periodic task at a fixed interval.
+func Every(interval time.Duration) Schedule {
+	return intervalSchedule(interval)
+}
+
+// Cron returns a schedule from a standard five-field cron expression, such as "0 3 * * *", or a
+// descriptor, such as "@daily". Times are evaluated in the local time zone unless the expression is prefixed
+// with one, such as "CRON_TZ=UTC 0 3 * * *".
+func Cron(expression string) (Schedule, error) {
+	s, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expression, err)
+	}
+	return s, nil
+}
+
+// periodicTask stores a task which is inserted on a schedule.
+type periodicTask struct {
+	name     string
+	kind     string
+	args     string
+	schedule Schedule
+}
+
+// RegisterPeriodic registers a task which will be inserted each time the schedule is due
+// The name uniquely identifies the periodic task and is used to track when it was last inserted, so it should
+// not change between deployments. A handler must also be registered for the task kind via Register().
+//
+// Only the worker currently elected as leader inserts periodic tasks, so running multiple workers does not
+// result in duplicates. River elects the leader through the river_leader table, rather than the
+// river_leader_clock table of the original schema, but the leader only tracks when each task is due in memory.
+// Each insert is therefore recorded in river_job_periodic, and a newly elected leader, or a previous leader
+// which has not yet noticed it was replaced, only inserts a task if it is due since it was last inserted.
+// A periodic task that was missed while no worker was running is inserted the next time it is due.
+//
+// The task is inserted by the constructor River calls when it is due, which returns nothing for River to
+// insert itself. River logs that at info level on every run, so the message is lowered to debug level.
+func RegisterPeriodic[T TaskArgs](w *Worker, name string, schedule Schedule, task T, opts ...InsertOption) error {
+	args, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode periodic task %s: %w", name, err)
+	}
+
+	// Validate the options now, but build them on each insert so they include the defaults of the task kind.
+	if _, err := buildInsertOpts(opts); err != nil {
+		return err
+	}
+
+	p := &periodicTask{
+		name:     name,
+		kind:     task.Kind(),
+		args:     string(args),
+		schedule: schedule,
+	}
+
+	// The task is inserted in the same transaction that records the insert, rather than returned to River,
+	// so a failed insert is not recorded and is retried the next time the task is due.
+	job := river.NewPeriodicJob(
+		schedule,
+		func() (river.JobArgs, *river.InsertOpts) {
+			ctx, cancel := context.WithTimeout(context.Background(), periodicClaimTimeout)
+			defer cancel()
+
+			insertOpts, err := buildInsertOpts(append(w.defaultInsertOptions(p.kind), opts...))
+			if err != nil {
+				log.Default().Error("failed to build periodic task options",
+					"name", p.name,
+					"error", err,
+				)
+				return nil, nil
+			}
+
+			inserted, err := w.claimPeriodic(ctx, p, func(tx *sql.Tx) error {
+				client, err := w.riverClient()
+				if err != nil {
+					return err
+				}
+				_, err = client.InsertTx(ctx, tx, task, insertOpts)
+				return err
+			})
+			switch {
+			case err != nil:
+				log.Default().Error("failed to insert periodic task",
+					"name", p.name,
+					"error", err,
+				)
+			case inserted:
+				log.Default().Info("inserted periodic task",
+					"name", p.name,
+					"kind", p.kind,
+				)
+			}
+
+			return nil, nil
+		},
+		nil,
+	)
+
+	// The client is given the periodic tasks when created, so any registered later are added to it directly.
+	w.clientMu.Lock()
+	defer w.clientMu.Unlock()
+	w.periodic = append(w.periodic, job)
+	if w.client != nil {
+		w.client.PeriodicJobs().Add(job)
+	}
+
+	return nil
+}
+
+// claimPeriodic inserts a periodic task if it is due, using the given function, and records the insert in the
+// same transaction. It reports whether the task was inserted.
+// The row is locked so that only a single worker can claim a given insert.
+func (w *Worker) claimPeriodic(ctx context.Context, p *periodicTask, insert func(tx *sql.Tx) error) (bool, error) {
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := time.Now()
+	next := p.schedule.Next(now)
+	interval := p.schedule.Next(next).Sub(next)
+
+	var (
+		state          string
+		lastInsertedAt time.Time
+	)
+	err = tx.QueryRowContext(ctx,
+		"SELECT state, last_inserted_at FROM river_job_periodic WHERE id = $1 FOR UPDATE",
+		p.name,
+	).Scan(&state, &lastInsertedAt)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO river_job_periodic (id, kind, args, interval_ms, state, last_inserted_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (id) DO NOTHING
+		`, p.name, p.kind, p.args, interval.Milliseconds(), periodicStateEnabled, now)
+		if err != nil {
+			return false, fmt.Errorf("failed to insert periodic task: %w", err)
+		}
+
+		// Another worker recorded the periodic task first.
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return false, err
+		}
+
+	case err != nil:
+		return false, fmt.Errorf("failed to query periodic task: %w", err)
+
+	case state != periodicStateEnabled:
+		return false, nil
+
+	case p.schedule.Next(lastInsertedAt).After(now.Add(periodicSlack)):
+		return false, nil
+
+	default:
+		_, err := tx.ExecContext(ctx, `
+			UPDATE river_job_periodic
+			SET kind = $2, args = $3, interval_ms = $4, last_inserted_at = $5
+			WHERE id = $1
+		`, p.name, p.kind, p.args, interval.Milliseconds(), now)
+		if err != nil {
+			return false, fmt.Errorf("failed to update periodic task: %w", err)
+		}
+	}
+
+	if err := insert(tx); err != nil {
+		return false, fmt.Errorf("failed to insert task: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// periodicLogHandler lowers the message River logs each time a periodic job constructor returns nil to debug
+// level, since RegisterPeriodic() always does so after inserting the task itself.
+type periodicLogHandler struct {
+	slog.Handler
+}
+
+// Handle handles the record, lowering its level if it reports a skipped periodic job.
+func (h periodicLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level == slog.LevelInfo && strings.HasSuffix(r.Message, periodicSkippedMessage) {
+		if !h.Handler.Enabled(ctx, slog.LevelDebug) {
+			return nil
+		}
+		r.Level = slog.LevelDebug
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler with the given attributes which still lowers skipped periodic job messages.
+func (h periodicLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return periodicLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup returns a handler with the given group which still lowers skipped periodic job messages.
+func (h periodicLogHandler) WithGroup(name string) slog.Handler {
+	return periodicLogHandler{h.Handler.WithGroup(name)}
+}
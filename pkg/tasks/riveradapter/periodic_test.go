@@ -0,0 +1,106 @@
+package riveradapter
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type periodicTestTask struct{}
+
+func (periodicTestTask) Kind() string {
+	return "periodic_test"
+}
+
+func TestEvery(t *testing.T) {
+	now := time.Now()
+	s := Every(time.Hour)
+	assert.Equal(t, now.Add(time.Hour), s.Next(now))
+}
+
+func TestCron(t *testing.T) {
+	s, err := Cron("CRON_TZ=UTC 0 3 * * *")
+	require.NoError(t, err)
+
+	from := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC), s.Next(from))
+
+	_, err = Cron("not a cron")
+	assert.Error(t, err)
+}
+
+func TestPeriodicLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(periodicLogHandler{slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})})
+	logger.With("service", "river").Info("PeriodicJobEnqueuer: " + periodicSkippedMessage)
+	assert.Empty(t, buf.String())
+
+	logger.Info("River client started")
+	assert.Contains(t, buf.String(), "level=INFO")
+
+	buf.Reset()
+	logger = slog.New(periodicLogHandler{slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})})
+	logger.Info("PeriodicJobEnqueuer: " + periodicSkippedMessage)
+	assert.Contains(t, buf.String(), "level=DEBUG")
+}
+
+func TestWorker_ClaimPeriodic(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	require.NoError(t, MigrateDB(ctx, db))
+
+	cleanup := func() {
+		_, _ = db.ExecContext(context.Background(), "DELETE FROM river_job_periodic WHERE id = 'periodic_test'")
+		_, _ = db.ExecContext(context.Background(), "DELETE FROM river_job WHERE kind = 'periodic_test'")
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	p := &periodicTask{name: "periodic_test", kind: "periodic_test", args: "{}", schedule: Every(time.Hour)}
+
+	// Two workers, such as a new leader and the one it replaced, claim the same tick at once
+	var wg sync.WaitGroup
+	inserted := make([]bool, 2)
+	for i := range inserted {
+		w, err := NewWorker(db, config.TasksConfig{Queues: map[string]int{"default": 1}})
+		require.NoError(t, err)
+		require.NoError(t, Register(w, func(context.Context, periodicTestTask) error { return nil }))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			inserted[i], err = w.claimPeriodic(ctx, p, func(tx *sql.Tx) error {
+				_, err := InsertTx(ctx, w, tx, periodicTestTask{})
+				return err
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []bool{true, false}, inserted)
+
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM river_job WHERE kind = 'periodic_test'").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	// The task is inserted again once it is due since it was last inserted
+	_, err := db.ExecContext(ctx,
+		"UPDATE river_job_periodic SET last_inserted_at = now() - interval '1 hour' WHERE id = 'periodic_test'")
+	require.NoError(t, err)
+
+	w, err := NewWorker(db, config.TasksConfig{Queues: map[string]int{"default": 1}})
+	require.NoError(t, err)
+	again, err := w.claimPeriodic(ctx, p, func(*sql.Tx) error { return nil })
+	require.NoError(t, err)
+	assert.True(t, again)
+}
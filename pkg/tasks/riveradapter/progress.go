@@ -0,0 +1,94 @@
+package riveradapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// metadataProgressKey is the job metadata key that progress is stored under.
+const metadataProgressKey = "progress"
+
+type (
+	// Progress describes how far along a task is, as reported by its handler.
+	Progress struct {
+		// Percent stores how complete the task is, from 0 to 100.
+		Percent int `json:"percent"`
+
+		// Message stores a description of what the task is currently doing.
+		Message string `json:"message"`
+
+		// UpdatedAt stores when the progress was reported.
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// jobContextKey is the context key that the job being processed is stored under.
+	jobContextKey struct{}
+
+	// jobContext identifies the job being processed.
+	jobContext struct {
+		worker *Worker
+		job    *RunningJob
+	}
+)
+
+// withJob returns a context for processing the given job.
+func withJob(ctx context.Context, w *Worker, job *RunningJob) context.Context {
+	return context.WithValue(ctx, jobContextKey{}, &jobContext{worker: w, job: job})
+}
+
+// JobID returns the ID of the job being processed, if the context belongs to a task handler.
+func JobID(ctx context.Context) (int64, bool) {
+	job, ok := CurrentJob(ctx)
+	if !ok {
+		return 0, false
+	}
+	return job.ID, true
+}
+
+// ReportProgress records the progress of the task being processed in its job metadata, so that it can be
+// displayed while the task runs. The context must be the one provided to the task handler.
+func ReportProgress(ctx context.Context, percent int, message string) error {
+	j, ok := ctx.Value(jobContextKey{}).(*jobContext)
+	if !ok {
+		return errors.New("context does not belong to a task handler")
+	}
+
+	progress, err := json.Marshal(map[string]Progress{
+		metadataProgressKey: {
+			Percent:   min(max(percent, 0), 100),
+			Message:   message,
+			UpdatedAt: time.Now(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode progress: %w", err)
+	}
+
+	if j.worker.memory != nil {
+		return j.worker.memory.mergeMetadata(j.job.ID, progress)
+	}
+
+	_, err = j.worker.db.ExecContext(ctx,
+		"UPDATE river_job SET metadata = metadata || $2::jsonb WHERE id = $1",
+		j.job.ID, string(progress),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record progress: %w", err)
+	}
+
+	return nil
+}
+
+// Progress returns the progress last reported by the task, if any.
+func (j *Job) Progress() *Progress {
+	var metadata struct {
+		Progress *Progress `json:"progress"`
+	}
+	if err := json.Unmarshal([]byte(j.Metadata), &metadata); err != nil {
+		return nil
+	}
+	return metadata.Progress
+}
@@ -0,0 +1,34 @@
+package riveradapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobID(t *testing.T) {
+	_, ok := JobID(context.Background())
+	assert.False(t, ok)
+
+	id, ok := JobID(withJob(context.Background(), &Worker{}, &RunningJob{ID: 123}))
+	assert.True(t, ok)
+	assert.Equal(t, int64(123), id)
+}
+
+func TestReportProgress_NoJob(t *testing.T) {
+	assert.Error(t, ReportProgress(context.Background(), 50, "halfway"))
+}
+
+func TestJob_Progress(t *testing.T) {
+	j := Job{Metadata: `{}`}
+	assert.Nil(t, j.Progress())
+
+	j.Metadata = `{"progress": {"percent": 40, "message": "Working", "updated_at": "2025-01-01T00:00:00Z"}}`
+	p := j.Progress()
+	require.NotNil(t, p)
+	assert.Equal(t, 40, p.Percent)
+	assert.Equal(t, "Working", p.Message)
+	assert.Equal(t, 2025, p.UpdatedAt.Year())
+}
@@ -0,0 +1,272 @@
+package riveradapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// discardHookTimeout is the maximum amount of time the discard hooks of a job may run for.
+const discardHookTimeout = 30 * time.Second
+
+type (
+	// RetryPolicy determines how many times, and how soon, a failed task is attempted again.
+	RetryPolicy struct {
+		// MaxAttempts is the maximum number of times a task is attempted before it is discarded.
+		// Zero uses River's default of 25. This can be overridden per task when inserting with MaxAttempts().
+		MaxAttempts int
+
+		// BaseDelay is how long to wait before the first retry. The delay doubles with each retry after that.
+		// Zero uses River's default backoff of the number of attempts to the fourth power, in seconds.
+		BaseDelay time.Duration
+
+		// MaxDelay caps the delay between retries. Zero means no limit.
+		MaxDelay time.Duration
+
+		// Jitter is the fraction of each delay, from 0 to 1, that is randomized so that tasks which failed
+		// at the same time are not all retried at the same time.
+		Jitter float64
+	}
+
+	// RegisterOption configures how tasks of a given kind are processed.
+	RegisterOption func(*kindOptions)
+
+	// DiscardHook is called when a task fails and will not be attempted again.
+	DiscardHook func(ctx context.Context, task DiscardedTask) error
+
+	// DiscardedTask describes a task that failed and will not be attempted again.
+	DiscardedTask struct {
+		// ID stores the ID of the job.
+		ID int64
+
+		// Kind stores the kind of the task.
+		Kind string
+
+		// Args stores the JSON-encoded task.
+		Args string
+
+		// Attempt stores the number of the attempt that failed.
+		Attempt int
+
+		// Error stores the error returned by the last attempt.
+		Error error
+
+		// Errors stores the errors returned by the previous attempts.
+		Errors []JobError
+
+		// NonRetryable indicates the task was discarded because it returned an error wrapped with
+		// NonRetryable(), rather than after exhausting its attempts.
+		NonRetryable bool
+	}
+
+	// kindOptions stores the options a task kind was registered with.
+	kindOptions struct {
+		queue     string
+		retry     *RetryPolicy
+		timeout   time.Duration
+		onDiscard []DiscardHook
+
+		// run processes a JSON-encoded task of the kind without River, for the in-memory worker.
+		run func(ctx context.Context, job *RunningJob, args []byte) error
+	}
+
+	// nonRetryableError wraps an error which should not cause its task to be attempted again.
+	nonRetryableError struct {
+		err error
+	}
+
+	// retryPolicy applies the retry policy registered for each task kind.
+	retryPolicy struct {
+		worker   *Worker
+		fallback river.ClientRetryPolicy
+	}
+
+	// errorHandler handles tasks that return errors.
+	errorHandler struct {
+		worker *Worker
+	}
+)
+
+// WithQueue sets the queue tasks of the kind are inserted in to, unless another is provided when inserting.
+func WithQueue(name string) RegisterOption {
+	return func(o *kindOptions) {
+		o.queue = name
+	}
+}
+
+// WithTimeout sets how long tasks of the kind may run for before their context is cancelled, overriding the
+// default provided to the Timeout() middleware. A negative timeout means no limit.
+func WithTimeout(timeout time.Duration) RegisterOption {
+	return func(o *kindOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetryPolicy sets the retry policy for the task kind.
+func WithRetryPolicy(policy RetryPolicy) RegisterOption {
+	return func(o *kindOptions) {
+		o.retry = &policy
+	}
+}
+
+// OnDiscard adds a hook that is called when a task of the kind fails and will not be attempted again,
+// either because it ran out of attempts or returned a NonRetryable() error. This is intended for critical
+// tasks which require someone to be notified when they fail.
+func OnDiscard(hook DiscardHook) RegisterOption {
+	return func(o *kindOptions) {
+		o.onDiscard = append(o.onDiscard, hook)
+	}
+}
+
+// NonRetryable wraps an error returned by a handler to indicate that the task should not be attempted again,
+// such as when its args are invalid. The task is discarded immediately with the error kept in its history.
+func NonRetryable(err error) error {
+	return &nonRetryableError{err: err}
+}
+
+func (e *nonRetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nonRetryableError) Unwrap() error {
+	return e.err
+}
+
+// Delay returns how long to wait before retrying a task that has failed the given number of times.
+func (p RetryPolicy) Delay(failures int) time.Duration {
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(failures-1))
+
+	if p.MaxDelay > 0 {
+		delay = math.Min(delay, float64(p.MaxDelay))
+	}
+	delay = math.Min(delay, math.MaxInt64)
+
+	// Only reduce the delay, so it never exceeds the maximum.
+	delay -= delay * p.Jitter * rand.Float64()
+
+	return time.Duration(delay)
+}
+
+// NextRetry returns when a failed job should next be attempted, using the retry policy of its kind if
+// one was registered.
+func (p *retryPolicy) NextRetry(job *rivertype.JobRow) time.Time {
+	if k, ok := p.worker.kinds[job.Kind]; ok && k.retry != nil && k.retry.BaseDelay > 0 {
+		// Count errors rather than attempts, which include snoozes. The current error is not yet recorded.
+		return time.Now().Add(k.retry.Delay(len(job.Errors) + 1))
+	}
+	return p.fallback.NextRetry(job)
+}
+
+// HandleError discards jobs which returned a non-retryable error, and calls the discard hooks of jobs which
+// will not be attempted again.
+func (h *errorHandler) HandleError(ctx context.Context, job *rivertype.JobRow, err error) *river.ErrorHandlerResult {
+	var nonRetryable *nonRetryableError
+	discard := errors.As(err, &nonRetryable)
+
+	// River discards a job which failed on its last attempt, checking the row passed here once this returns,
+	// so the job is treated as out of attempts. Cancelling it instead would lose the discarded state, and a
+	// retry policy cannot discard, so TestErrorHandler_NonRetryable pins this behaviour of River.
+	if discard {
+		job.MaxAttempts = job.Attempt
+	}
+
+	if job.Attempt >= job.MaxAttempts {
+		h.worker.discarded(ctx, job, err, discard)
+	}
+
+	return nil
+}
+
+// HandlePanic calls the discard hooks of jobs which panicked and will not be attempted again.
+func (h *errorHandler) HandlePanic(ctx context.Context, job *rivertype.JobRow, panicVal any, trace string) *river.ErrorHandlerResult {
+	if job.Attempt >= job.MaxAttempts {
+		h.worker.discarded(ctx, job, fmt.Errorf("panic: %v", panicVal), false)
+	}
+	return nil
+}
+
+// discarded calls the discard hooks registered for the kind of a job which will not be attempted again.
+// The hooks run in the background, with their own timeout, so they do not hold up the job's slot in its queue
+// or fail because the job's context has ended.
+func (w *Worker) discarded(ctx context.Context, job *rivertype.JobRow, err error, nonRetryable bool) {
+	k, ok := w.kinds[job.Kind]
+	if !ok || len(k.onDiscard) == 0 {
+		return
+	}
+
+	task := DiscardedTask{
+		ID:           job.ID,
+		Kind:         job.Kind,
+		Args:         string(job.EncodedArgs),
+		Attempt:      job.Attempt,
+		Error:        err,
+		NonRetryable: nonRetryable,
+	}
+	for _, e := range job.Errors {
+		task.Errors = append(task.Errors, JobError{
+			At:      e.At,
+			Attempt: e.Attempt,
+			Error:   e.Error,
+			Trace:   e.Trace,
+		})
+	}
+
+	w.hooks.Add(1)
+	go func() {
+		defer w.hooks.Done()
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), discardHookTimeout)
+		defer cancel()
+
+		for _, hook := range k.onDiscard {
+			if err := hook(ctx, task); err != nil {
+				log.Default().Error("discard hook failed",
+					"job_id", task.ID,
+					"kind", task.Kind,
+					"error", err,
+				)
+			}
+		}
+	}()
+}
+
+// waitForHooks waits for the discard hooks which are still running to return.
+func (w *Worker) waitForHooks(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		w.hooks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("discard hooks did not complete in time: %w", ctx.Err())
+	}
+}
+
+// defaultInsertOptions returns the insert options registered for a task kind, which are applied before
+// those provided when inserting.
+func (w *Worker) defaultInsertOptions(kind string) []InsertOption {
+	k, ok := w.kinds[kind]
+	if !ok {
+		return nil
+	}
+
+	var opts []InsertOption
+	if k.queue != "" {
+		opts = append(opts, Queue(k.queue))
+	}
+	if k.retry != nil && k.retry.MaxAttempts > 0 {
+		opts = append(opts, MaxAttempts(k.retry.MaxAttempts))
+	}
+	return opts
+}
@@ -0,0 +1,192 @@
+package riveradapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/riverqueue/river/rivertype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type retryTestTask struct{}
+
+func (retryTestTask) Kind() string {
+	return "retry_test"
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  time.Minute,
+	}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 8*time.Second, p.Delay(4))
+	assert.Equal(t, time.Minute, p.Delay(10))
+	assert.Equal(t, time.Minute, p.Delay(5000))
+
+	p.Jitter = 0.5
+	for range 100 {
+		d := p.Delay(4)
+		assert.LessOrEqual(t, d, 8*time.Second)
+		assert.GreaterOrEqual(t, d, 4*time.Second)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	var discarded []DiscardedTask
+	w := &Worker{
+		kinds: map[string]*kindOptions{
+			"critical": {
+				onDiscard: []DiscardHook{
+					func(ctx context.Context, task DiscardedTask) error {
+						discarded = append(discarded, task)
+						return nil
+					},
+				},
+			},
+		},
+	}
+	h := &errorHandler{worker: w}
+	errTask := errors.New("failed")
+
+	job := &rivertype.JobRow{
+		ID:          1,
+		Kind:        "critical",
+		Attempt:     1,
+		MaxAttempts: 3,
+		EncodedArgs: []byte(`{"a":1}`),
+	}
+
+	// Retried.
+	res := h.HandleError(context.Background(), job, errTask)
+	w.hooks.Wait()
+	assert.Nil(t, res)
+	assert.Equal(t, 3, job.MaxAttempts)
+	assert.Empty(t, discarded)
+
+	// Non-retryable jobs are discarded by running out of attempts, rather than cancelled.
+	res = h.HandleError(context.Background(), job, NonRetryable(errTask))
+	w.hooks.Wait()
+	assert.Nil(t, res)
+	assert.Equal(t, 1, job.MaxAttempts)
+	require.Len(t, discarded, 1)
+	assert.True(t, discarded[0].NonRetryable)
+	assert.ErrorIs(t, discarded[0].Error, errTask)
+
+	// Out of attempts.
+	job.Attempt = 3
+	job.MaxAttempts = 3
+	job.Errors = []rivertype.AttemptError{{Attempt: 1, Error: "failed"}, {Attempt: 2, Error: "failed"}}
+	res = h.HandleError(context.Background(), job, errTask)
+	w.hooks.Wait()
+	assert.Nil(t, res)
+	require.Len(t, discarded, 2)
+	assert.False(t, discarded[1].NonRetryable)
+	assert.Equal(t, `{"a":1}`, discarded[1].Args)
+	assert.Len(t, discarded[1].Errors, 2)
+
+	// Kinds without hooks.
+	job.Kind = "other"
+	h.HandleError(context.Background(), job, errTask)
+	w.hooks.Wait()
+	assert.Len(t, discarded, 2)
+}
+
+func TestErrorHandler_HookContext(t *testing.T) {
+	var hookErr error
+	w := &Worker{
+		kinds: map[string]*kindOptions{
+			"critical": {
+				onDiscard: []DiscardHook{
+					func(ctx context.Context, task DiscardedTask) error {
+						hookErr = ctx.Err()
+						_, hasDeadline := ctx.Deadline()
+						assert.True(t, hasDeadline)
+						return nil
+					},
+				},
+			},
+		},
+	}
+
+	// The hooks outlive the job, whose context ends once it has been handled.
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &rivertype.JobRow{ID: 1, Kind: "critical", Attempt: 3, MaxAttempts: 3}
+	(&errorHandler{worker: w}).HandleError(ctx, job, errors.New("failed"))
+	cancel()
+
+	require.NoError(t, w.waitForHooks(context.Background()))
+	assert.NoError(t, hookErr)
+}
+
+func TestErrorHandler_NonRetryable(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	require.NoError(t, MigrateDB(ctx, db))
+	t.Cleanup(func() {
+		_, _ = db.ExecContext(context.Background(), "DELETE FROM river_job WHERE kind = 'retry_test'")
+	})
+
+	w, err := NewWorker(db, config.TasksConfig{Queues: map[string]int{"retry_test": 1}})
+	require.NoError(t, err)
+
+	hooked := make(chan DiscardedTask, 1)
+	require.NoError(t, Register(w,
+		func(context.Context, retryTestTask) error {
+			return NonRetryable(errors.New("invalid args"))
+		},
+		WithQueue("retry_test"),
+		OnDiscard(func(ctx context.Context, task DiscardedTask) error {
+			hooked <- task
+			return nil
+		}),
+	))
+	require.NoError(t, w.Start(ctx))
+	t.Cleanup(func() { _ = w.Stop(context.Background()) })
+
+	res, err := Insert(ctx, w, retryTestTask{}, MaxAttempts(5))
+	require.NoError(t, err)
+
+	select {
+	case task := <-hooked:
+		assert.True(t, task.NonRetryable)
+	case <-time.After(10 * time.Second):
+		t.Fatal("discard hook was not called")
+	}
+
+	// River discards the job after its first attempt, rather than retrying or cancelling it.
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		job, err := w.GetJob(ctx, res.ID)
+		require.NoError(c, err)
+		assert.Equal(c, JobStateDiscarded, job.State)
+		assert.Equal(c, 1, job.Attempt)
+		if assert.Len(c, job.Errors, 1) {
+			assert.Equal(c, "invalid args", job.Errors[0].Error)
+		}
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestWorker_DefaultInsertOptions(t *testing.T) {
+	w := &Worker{
+		kinds: map[string]*kindOptions{
+			"limited": {queue: "mail", retry: &RetryPolicy{MaxAttempts: 5}},
+		},
+	}
+
+	opts, err := buildInsertOpts(w.defaultInsertOptions("limited"))
+	require.NoError(t, err)
+	assert.Equal(t, 5, opts.MaxAttempts)
+	assert.Equal(t, "mail", opts.Queue)
+
+	opts, err = buildInsertOpts(append(w.defaultInsertOptions("limited"), MaxAttempts(2), Queue("other")))
+	require.NoError(t, err)
+	assert.Equal(t, 2, opts.MaxAttempts)
+	assert.Equal(t, "other", opts.Queue)
+
+	assert.Empty(t, w.defaultInsertOptions("other"))
+}
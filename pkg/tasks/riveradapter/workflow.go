@@ -0,0 +1,578 @@
+package riveradapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+)
+
+const (
+	// metadataWorkflowKey is the job metadata key that the workflow a job belongs to is stored under.
+	metadataWorkflowKey = "workflow"
+
+	// workflowSweepInterval is how often the leader checks workflows with pending jobs, in case a job was
+	// finalized without its workflow being advanced, such as when a worker stopped in between.
+	workflowSweepInterval = time.Minute
+
+	// workflowAdvanceTimeout is the maximum amount of time to wait when advancing a workflow.
+	workflowAdvanceTimeout = 10 * time.Second
+)
+
+// Workflow states.
+const (
+	// WorkflowStateRunning indicates the workflow has tasks which have not yet completed.
+	WorkflowStateRunning = "running"
+
+	// WorkflowStateCompleted indicates all tasks in the workflow have completed.
+	WorkflowStateCompleted = "completed"
+
+	// WorkflowStateFailed indicates a task in the workflow was discarded or cancelled, so the tasks which
+	// depend on it will never run.
+	WorkflowStateFailed = "failed"
+)
+
+// ErrWorkflowNotFound is returned when a workflow does not exist.
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+type (
+	// Workflow is a group of tasks with dependencies between them, such as "process upload", then
+	// "generate thumbnails", then "notify user". A task only runs once all the tasks it depends on have
+	// completed, and is cancelled if any of them is discarded or cancelled.
+	Workflow struct {
+		name  string
+		tasks []*workflowTask
+		err   error
+	}
+
+	// workflowTask stores a task added to a workflow.
+	workflowTask struct {
+		name      string
+		task      TaskArgs
+		dependsOn []string
+		opts      []InsertOption
+	}
+
+	// workflowMetadata is stored in the metadata of each job in a workflow.
+	workflowMetadata struct {
+		ID        string   `json:"id"`
+		Name      string   `json:"name"`
+		Task      string   `json:"task"`
+		DependsOn []string `json:"depends_on,omitempty"`
+	}
+
+	// WorkflowResult describes a workflow that has been inserted.
+	WorkflowResult struct {
+		// ID stores the ID of the workflow.
+		ID string
+
+		// Jobs stores the ID of the job inserted for each task, keyed by task name.
+		Jobs map[string]int64
+	}
+
+	// WorkflowStatus describes the current state of a workflow.
+	WorkflowStatus struct {
+		// ID stores the ID of the workflow.
+		ID string
+
+		// Name stores the name the workflow was created with.
+		Name string
+
+		// State stores the overall state of the workflow.
+		State string
+
+		// Tasks stores the tasks in the workflow, in the order they were added.
+		Tasks []WorkflowTask
+	}
+
+	// WorkflowTask describes a task in a workflow.
+	WorkflowTask struct {
+		// Name stores the name of the task within the workflow.
+		Name string
+
+		// DependsOn stores the names of the tasks which must complete before this one runs.
+		DependsOn []string
+
+		// Job stores the job inserted for the task.
+		Job *Job
+	}
+
+	// workflowCancel describes a job which can never run because a task it depends on failed.
+	workflowCancel struct {
+		id     int64
+		reason string
+	}
+)
+
+// NewWorkflow creates a new, empty workflow.
+// The name describes the workflow and does not need to be unique.
+func NewWorkflow(name string) *Workflow {
+	return &Workflow{name: name}
+}
+
+// Add adds a task to the workflow, which will only run once all the tasks it depends on have completed
+// The name identifies the task within the workflow. Dependencies must be added before the tasks which depend
+// on them, so the workflow can not contain cycles. Tasks cannot be unique.
+func (wf *Workflow) Add(name string, task TaskArgs, dependsOn []string, opts ...InsertOption) *Workflow {
+	switch {
+	case wf.err != nil:
+		return wf
+	case name == "":
+		wf.err = errors.New("workflow task name is required")
+		return wf
+	case wf.task(name) != nil:
+		wf.err = fmt.Errorf("workflow task %q was already added", name)
+		return wf
+	}
+
+	for _, dep := range dependsOn {
+		if wf.task(dep) == nil {
+			wf.err = fmt.Errorf("workflow task %q depends on %q, which must be added first", name, dep)
+			return wf
+		}
+	}
+
+	wf.tasks = append(wf.tasks, &workflowTask{
+		name:      name,
+		task:      task,
+		dependsOn: dependsOn,
+		opts:      opts,
+	})
+	return wf
+}
+
+// task returns the task with the given name, if it was added.
+func (wf *Workflow) task(name string) *workflowTask {
+	for _, t := range wf.tasks {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// InsertWorkflow inserts all the tasks in a workflow within a single transaction.
+// Tasks without dependencies are available to run immediately, while the rest wait until their dependencies
+// have completed.
+func InsertWorkflow(ctx context.Context, w *Worker, wf *Workflow) (*WorkflowResult, error) {
+	if w.memory != nil {
+		return InsertWorkflowTx(ctx, w, nil, wf)
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := InsertWorkflowTx(ctx, w, tx, wf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return res, nil
+}
+
+// InsertWorkflowTx inserts all the tasks in a workflow within a database transaction.
+// See InsertWorkflow() and InsertTx().
+func InsertWorkflowTx(ctx context.Context, w *Worker, tx *sql.Tx, wf *Workflow) (*WorkflowResult, error) {
+	switch {
+	case wf.err != nil:
+		return nil, wf.err
+	case len(wf.tasks) == 0:
+		return nil, errors.New("workflow has no tasks")
+	}
+
+	res := &WorkflowResult{
+		ID:   uuid.NewString(),
+		Jobs: make(map[string]int64, len(wf.tasks)),
+	}
+
+	for _, t := range wf.tasks {
+		opts := append(w.insertOptions(ctx, t.task.Kind(), t.opts), Metadata(map[string]any{
+			metadataWorkflowKey: workflowMetadata{
+				ID:        res.ID,
+				Name:      wf.name,
+				Task:      t.name,
+				DependsOn: t.dependsOn,
+			},
+		}))
+
+		insertOpts, err := buildInsertOpts(opts)
+		if err != nil {
+			return nil, err
+		}
+		if isUnique(insertOpts.UniqueOpts) {
+			return nil, fmt.Errorf("workflow task %q cannot be unique", t.name)
+		}
+
+		// Tasks with dependencies are held until the workflow is advanced.
+		insertOpts.Pending = len(t.dependsOn) > 0
+
+		var id int64
+		if w.memory != nil {
+			r, err := w.memory.insert(t.task, insertOpts)
+			if err != nil {
+				return nil, err
+			}
+			id = r.ID
+		} else {
+			client, err := w.riverClient()
+			if err != nil {
+				return nil, err
+			}
+
+			r, err := client.InsertTx(ctx, tx, t.task, insertOpts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert workflow task %q: %w", t.name, err)
+			}
+			id = r.Job.ID
+		}
+		res.Jobs[t.name] = id
+	}
+
+	return res, nil
+}
+
+// GetWorkflow returns the current state of a workflow.
+// ErrWorkflowNotFound is returned if the workflow does not exist.
+func (w *Worker) GetWorkflow(ctx context.Context, id string) (*WorkflowStatus, error) {
+	var (
+		jobs []*Job
+		err  error
+	)
+	if w.memory != nil {
+		jobs = w.memory.workflowJobs(id)
+	} else {
+		jobs, err = queryWorkflowJobs(ctx, w.db, id, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(jobs) == 0 {
+		return nil, ErrWorkflowNotFound
+	}
+
+	status := &WorkflowStatus{
+		ID:    id,
+		State: WorkflowStateCompleted,
+	}
+
+	for _, j := range jobs {
+		meta := jobWorkflow(j.Metadata)
+		status.Name = meta.Name
+		status.Tasks = append(status.Tasks, WorkflowTask{
+			Name:      meta.Task,
+			DependsOn: meta.DependsOn,
+			Job:       j,
+		})
+
+		switch {
+		case j.State == JobStateDiscarded, j.State == JobStateCancelled:
+			status.State = WorkflowStateFailed
+		case j.State != JobStateCompleted && status.State == WorkflowStateCompleted:
+			status.State = WorkflowStateRunning
+		}
+	}
+
+	return status, nil
+}
+
+// jobWorkflow returns the workflow stored in the metadata of a job, or nil if it does not belong to one.
+func jobWorkflow(metadata string) *workflowMetadata {
+	var m struct {
+		Workflow *workflowMetadata `json:"workflow"`
+	}
+	if err := json.Unmarshal([]byte(metadata), &m); err != nil || m.Workflow == nil || m.Workflow.ID == "" {
+		return nil
+	}
+	return m.Workflow
+}
+
+// resolveWorkflow determines which pending jobs of a workflow are ready to run, because all the tasks they
+// depend on have completed, and which will never run, because a task they depend on was discarded or cancelled.
+// The jobs must be ordered by ID, which places every task after those it depends on.
+func resolveWorkflow(jobs []*Job) ([]int64, []workflowCancel) {
+	var (
+		ready     []int64
+		cancelled []workflowCancel
+		states    = make(map[string]string, len(jobs))
+	)
+
+	for _, j := range jobs {
+		if meta := jobWorkflow(j.Metadata); meta != nil {
+			states[meta.Task] = j.State
+		}
+	}
+
+	for _, j := range jobs {
+		meta := jobWorkflow(j.Metadata)
+		if meta == nil || j.State != JobStatePending {
+			continue
+		}
+
+		waiting := false
+		for _, dep := range meta.DependsOn {
+			switch states[dep] {
+			case JobStateCompleted:
+			case JobStateDiscarded, JobStateCancelled:
+				if states[meta.Task] == JobStatePending {
+					// Cancelling the job cascades to the tasks which depend on it.
+					states[meta.Task] = JobStateCancelled
+					cancelled = append(cancelled, workflowCancel{
+						id:     j.ID,
+						reason: fmt.Sprintf("workflow task %q was %s", dep, states[dep]),
+					})
+				}
+			default:
+				waiting = true
+			}
+		}
+
+		if !waiting && states[meta.Task] == JobStatePending {
+			ready = append(ready, j.ID)
+		}
+	}
+
+	return ready, cancelled
+}
+
+// queryWorkflowJobs returns the jobs of a workflow ordered by ID, optionally locking them.
+func queryWorkflowJobs(ctx context.Context, db interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}, id string, lock bool) ([]*Job, error) {
+	contains, err := json.Marshal(map[string]any{
+		metadataWorkflowKey: map[string]string{"id": id},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + jobColumns + " FROM river_job WHERE metadata @> $1::jsonb ORDER BY id"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := db.QueryContext(ctx, query, string(contains))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflow jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// advanceWorkflow makes the pending jobs of a workflow available once the tasks they depend on have completed,
+// and cancels those which depend on a task that was discarded or cancelled.
+func (w *Worker) advanceWorkflow(ctx context.Context, id string) error {
+	if w.memory != nil {
+		w.memory.advanceWorkflow(id)
+		return nil
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// Lock the jobs so that concurrent advances of the same workflow are serialized.
+	jobs, err := queryWorkflowJobs(ctx, tx, id, true)
+	if err != nil {
+		return err
+	}
+
+	ready, cancelled := resolveWorkflow(jobs)
+	if len(ready) == 0 && len(cancelled) == 0 {
+		return nil
+	}
+
+	if len(ready) > 0 {
+		// Wake the workers of the queues the jobs are now available in, as is done when inserting.
+		_, err := tx.ExecContext(ctx, `
+			WITH ready AS (
+				UPDATE river_job
+				SET state = 'available', scheduled_at = now()
+				WHERE id = ANY($1) AND state = 'pending'
+				RETURNING queue
+			)
+			SELECT pg_notify(concat(current_schema(), '.river_insert'), json_build_object('queue', queue)::text)
+			FROM (SELECT DISTINCT queue FROM ready) q
+		`, ready)
+		if err != nil {
+			return fmt.Errorf("failed to make workflow jobs available: %w", err)
+		}
+	}
+
+	for _, c := range cancelled {
+		jobErr, err := json.Marshal(JobError{
+			At:    time.Now(),
+			Error: c.reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE river_job
+			SET state = 'cancelled', finalized_at = now(), errors = array_append(errors, $2::jsonb)
+			WHERE id = $1 AND state = 'pending'
+		`, c.id, string(jobErr))
+		if err != nil {
+			return fmt.Errorf("failed to cancel workflow job %d: %w", c.id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// advanceWorkflowsOf advances the workflows the given jobs belong to, after they were changed outside of River.
+func (w *Worker) advanceWorkflowsOf(ctx context.Context, ids []int64) error {
+	var workflows []string
+	if w.memory != nil {
+		for _, id := range ids {
+			if j, err := w.memory.getJob(id); err == nil {
+				if meta := jobWorkflow(j.Metadata); meta != nil && !slices.Contains(workflows, meta.ID) {
+					workflows = append(workflows, meta.ID)
+				}
+			}
+		}
+	} else {
+		rows, err := w.db.QueryContext(ctx, `
+			SELECT DISTINCT metadata->'workflow'->>'id'
+			FROM river_job
+			WHERE id = ANY($1) AND metadata ? 'workflow'
+		`, ids)
+		if err != nil {
+			return fmt.Errorf("failed to query workflows: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return fmt.Errorf("failed to scan workflow: %w", err)
+			}
+			workflows = append(workflows, id)
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to query workflows: %w", err)
+		}
+	}
+
+	var errs []error
+	for _, id := range workflows {
+		errs = append(errs, w.advanceWorkflow(ctx, id))
+	}
+	return errors.Join(errs...)
+}
+
+// watchWorkflows advances workflows as their jobs are finalized, until the subscription is closed when the
+// client stops.
+func (w *Worker) watchWorkflows(events <-chan *river.Event) {
+	for e := range events {
+		switch e.Job.State {
+		case rivertype.JobStateCompleted, rivertype.JobStateDiscarded, rivertype.JobStateCancelled:
+			if meta := jobWorkflow(string(e.Job.Metadata)); meta != nil {
+				w.tryAdvanceWorkflow(meta.ID)
+			}
+		}
+	}
+}
+
+// workflowSweepJob returns a periodic job which sweeps workflows with pending jobs, in case an event was missed.
+// River only runs periodic jobs on the worker elected as leader, so a single worker sweeps at a time. As with
+// RegisterPeriodic(), the work is done by the constructor, which returns nothing for River to insert.
+func (w *Worker) workflowSweepJob() *river.PeriodicJob {
+	return river.NewPeriodicJob(
+		river.PeriodicInterval(workflowSweepInterval),
+		func() (river.JobArgs, *river.InsertOpts) {
+			w.sweepWorkflows()
+			return nil, nil
+		},
+		nil,
+	)
+}
+
+// sweepWorkflows advances the workflows which have pending jobs.
+func (w *Worker) sweepWorkflows() {
+	ids, err := w.pendingWorkflows()
+	if err != nil {
+		log.Default().Error("failed to query pending workflows",
+			"error", err,
+		)
+	}
+	for _, id := range ids {
+		w.tryAdvanceWorkflow(id)
+	}
+}
+
+// tryAdvanceWorkflow advances a workflow, logging any error.
+func (w *Worker) tryAdvanceWorkflow(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), workflowAdvanceTimeout)
+	defer cancel()
+
+	if err := w.advanceWorkflow(ctx, id); err != nil {
+		log.Default().Error("failed to advance workflow",
+			"workflow_id", id,
+			"error", err,
+		)
+	}
+}
+
+// pendingWorkflows returns the IDs of the workflows which have pending jobs.
+func (w *Worker) pendingWorkflows() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), workflowAdvanceTimeout)
+	defer cancel()
+
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT DISTINCT metadata->'workflow'->>'id'
+		FROM river_job
+		WHERE state = 'pending' AND metadata ? 'workflow'
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
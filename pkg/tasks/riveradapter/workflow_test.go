@@ -0,0 +1,161 @@
+package riveradapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/edkadigital/startmeup/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflow_Add(t *testing.T) {
+	wf := NewWorkflow("test").
+		Add("a", memoryTestTask{}, nil).
+		Add("b", memoryTestTask{}, []string{"a"})
+	assert.NoError(t, wf.err)
+	assert.Len(t, wf.tasks, 2)
+
+	wf = NewWorkflow("test").Add("a", memoryTestTask{}, []string{"b"})
+	assert.Error(t, wf.err)
+
+	wf = NewWorkflow("test").Add("a", memoryTestTask{}, nil).Add("a", memoryTestTask{}, nil)
+	assert.Error(t, wf.err)
+
+	wf = NewWorkflow("test").Add("", memoryTestTask{}, nil)
+	assert.Error(t, wf.err)
+}
+
+func TestResolveWorkflow(t *testing.T) {
+	job := func(id int64, state, metadata string) *Job {
+		return &Job{ID: id, State: state, Metadata: metadata}
+	}
+
+	ready, cancelled := resolveWorkflow([]*Job{
+		job(1, JobStateCompleted, `{"workflow": {"id": "w", "task": "a"}}`),
+		job(2, JobStateDiscarded, `{"workflow": {"id": "w", "task": "b"}}`),
+		job(3, JobStateRunning, `{"workflow": {"id": "w", "task": "c"}}`),
+		job(4, JobStatePending, `{"workflow": {"id": "w", "task": "d", "depends_on": ["a"]}}`),
+		job(5, JobStatePending, `{"workflow": {"id": "w", "task": "e", "depends_on": ["a", "b"]}}`),
+		job(6, JobStatePending, `{"workflow": {"id": "w", "task": "f", "depends_on": ["e"]}}`),
+		job(7, JobStatePending, `{"workflow": {"id": "w", "task": "g", "depends_on": ["a", "c"]}}`),
+	})
+	assert.Equal(t, []int64{4}, ready)
+	assert.Equal(t, []workflowCancel{
+		{id: 5, reason: `workflow task "b" was discarded`},
+		{id: 6, reason: `workflow task "e" was cancelled`},
+	}, cancelled)
+}
+
+func TestInsertWorkflow(t *testing.T) {
+	var processed []string
+	w := newMemoryTestWorker(t, func(ctx context.Context, task memoryTestTask) error {
+		processed = append(processed, task.Value)
+		if task.Value == "thumbnails" {
+			return NonRetryable(errors.New("invalid image"))
+		}
+		return nil
+	})
+	ctx := context.Background()
+
+	wf := NewWorkflow("upload").
+		Add("process", memoryTestTask{Value: "process"}, nil).
+		Add("scan", memoryTestTask{Value: "scan"}, nil).
+		Add("index", memoryTestTask{Value: "index"}, []string{"process", "scan"}).
+		Add("notify", memoryTestTask{Value: "notify"}, []string{"index"})
+
+	res, err := InsertWorkflow(ctx, w, wf)
+	require.NoError(t, err)
+	assert.Len(t, res.Jobs, 4)
+
+	status, err := w.GetWorkflow(ctx, res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "upload", status.Name)
+	assert.Equal(t, WorkflowStateRunning, status.State)
+	require.Len(t, status.Tasks, 4)
+	assert.Equal(t, "index", status.Tasks[2].Name)
+	assert.Equal(t, []string{"process", "scan"}, status.Tasks[2].DependsOn)
+	assert.Equal(t, JobStatePending, status.Tasks[2].Job.State)
+
+	require.NoError(t, w.Memory().Drain(ctx))
+	assert.Equal(t, []string{"process", "scan", "index", "notify"}, processed)
+
+	status, err = w.GetWorkflow(ctx, res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, WorkflowStateCompleted, status.State)
+
+	// Tasks downstream of one which fails are cancelled.
+	processed = nil
+	wf = NewWorkflow("upload").
+		Add("process", memoryTestTask{Value: "process"}, nil).
+		Add("thumbnails", memoryTestTask{Value: "thumbnails"}, []string{"process"}).
+		Add("notify", memoryTestTask{Value: "notify"}, []string{"thumbnails"})
+
+	res, err = InsertWorkflow(ctx, w, wf)
+	require.NoError(t, err)
+	assert.Error(t, w.Memory().Drain(ctx))
+	assert.Equal(t, []string{"process", "thumbnails"}, processed)
+
+	status, err = w.GetWorkflow(ctx, res.ID)
+	require.NoError(t, err)
+	assert.Equal(t, WorkflowStateFailed, status.State)
+	assert.Equal(t, JobStateCancelled, status.Tasks[2].Job.State)
+	require.Len(t, status.Tasks[2].Job.Errors, 1)
+	assert.Equal(t, `workflow task "thumbnails" was discarded`, status.Tasks[2].Job.Errors[0].Error)
+
+	// Discarding a task waiting to run also cancels those downstream.
+	wf = NewWorkflow("upload").
+		Add("process", memoryTestTask{Value: "process"}, nil).
+		Add("notify", memoryTestTask{Value: "notify"}, []string{"process"})
+
+	res, err = InsertWorkflow(ctx, w, wf)
+	require.NoError(t, err)
+	n, err := w.DiscardJobs(ctx, res.Jobs["process"])
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	job, err := w.GetJob(ctx, res.Jobs["notify"])
+	require.NoError(t, err)
+	assert.Equal(t, JobStateCancelled, job.State)
+
+	_, err = w.GetWorkflow(ctx, "missing")
+	assert.ErrorIs(t, err, ErrWorkflowNotFound)
+
+	_, err = InsertWorkflow(ctx, w, NewWorkflow("empty"))
+	assert.Error(t, err)
+
+	_, err = InsertWorkflow(ctx, w, NewWorkflow("unique").Add("a", memoryTestTask{}, nil, Unique()))
+	assert.Error(t, err)
+}
+
+func TestWorker_SweepWorkflows(t *testing.T) {
+	db := openTestDB(t)
+	ctx := t.Context()
+	require.NoError(t, MigrateDB(ctx, db))
+
+	w, err := NewWorker(db, config.TasksConfig{Queues: map[string]int{"default": 1}})
+	require.NoError(t, err)
+	require.NoError(t, Register(w, func(context.Context, memoryTestTask) error { return nil }))
+
+	wf := NewWorkflow("sweep").
+		Add("first", memoryTestTask{}, nil).
+		Add("second", memoryTestTask{}, []string{"first"})
+	res, err := InsertWorkflow(ctx, w, wf)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = db.ExecContext(context.Background(),
+			"DELETE FROM river_job WHERE metadata->'workflow'->>'id' = $1", res.ID)
+	})
+
+	// The first task completes without its workflow being advanced, as if the worker stopped in between.
+	_, err = db.ExecContext(ctx,
+		"UPDATE river_job SET state = 'completed', finalized_at = now() WHERE id = $1", res.Jobs["first"])
+	require.NoError(t, err)
+
+	w.sweepWorkflows()
+
+	job, err := w.GetJob(ctx, res.Jobs["second"])
+	require.NoError(t, err)
+	assert.Equal(t, JobStateAvailable, job.State)
+}
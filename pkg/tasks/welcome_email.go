@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/edkadigital/startmeup/ent"
+	"github.com/edkadigital/startmeup/pkg/log"
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/services"
+	"github.com/edkadigital/startmeup/pkg/ui/emails"
+)
+
+// SendWelcomeEmailTask sends a welcome email to a newly registered user.
+// This should be inserted in the same transaction that creates the user so the email is never sent for a
+// user that was not saved.
+type SendWelcomeEmailTask struct {
+	UserID int `json:"user_id"`
+}
+
+// Kind returns the kind used to route the task to its handler.
+func (SendWelcomeEmailTask) Kind() string {
+	return "send_welcome_email"
+}
+
+// NewSendWelcomeEmailHandler provides a handler that processes SendWelcomeEmailTask tasks.
+func NewSendWelcomeEmailHandler(c *services.Container) func(ctx context.Context, task SendWelcomeEmailTask) error {
+	return func(ctx context.Context, task SendWelcomeEmailTask) error {
+		u, err := c.ORM.User.Get(ctx, task.UserID)
+		switch {
+		case ent.IsNotFound(err):
+			log.FromContext(ctx).Warn("skipping welcome email for deleted user",
+				"user_id", task.UserID,
+			)
+			return nil
+		case err != nil:
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+
+		return c.Mail.
+			Compose().
+			To(u.Email).
+			Subject(fmt.Sprintf("Welcome to %s", c.Config.App.Name)).
+			Component(emails.Welcome(c.Config.App.Name, u.Name, c.Config.App.Host+c.Web.Reverse(routenames.Login))).
+			SendContext(ctx)
+	}
+}
@@ -2,6 +2,7 @@ package components
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/edkadigital/startmeup/pkg/ui"
 	. "maragu.dev/gomponents"
@@ -37,3 +38,11 @@ func HtmxListeners(r *ui.Request) Node {
 func HxBoost() Node {
 	return Attr("hx-boost", "true")
 }
+
+func HxPoll(url string, interval time.Duration) Node {
+	return Group{
+		Attr("hx-get", url),
+		Attr("hx-trigger", fmt.Sprintf("every %dms", interval.Milliseconds())),
+		Attr("hx-swap", "outerHTML"),
+	}
+}
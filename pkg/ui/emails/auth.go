@@ -20,3 +20,13 @@ func ConfirmEmailAddress(ctx echo.Context, username, token string) Node {
 		A(Href(url), Text(url)),
 	}
 }
+
+func Welcome(appName, username, url string) Node {
+	return Group{
+		Strong(Textf("Hello %s,", username)),
+		Br(),
+		P(Textf("Welcome to %s! Your account has been created and you can log in at any time:", appName)),
+		Br(),
+		A(Href(url), Text(url)),
+	}
+}
@@ -0,0 +1,16 @@
+package emails
+
+import (
+	. "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+func TaskDiscarded(kind string, id int64, attempt int, err, url string) Node {
+	return Group{
+		P(Textf("The %s task (ID %d) failed on attempt %d and will not be attempted again:", kind, id, attempt)),
+		Pre(Text(err)),
+		Br(),
+		P(Text("The task and its error history can be viewed and retried here:")),
+		A(Href(url), Text(url)),
+	}
+}
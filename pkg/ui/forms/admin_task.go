@@ -0,0 +1,55 @@
+package forms
+
+import (
+	"net/http"
+
+	"github.com/edkadigital/startmeup/pkg/form"
+	"github.com/edkadigital/startmeup/pkg/ui"
+	. "github.com/edkadigital/startmeup/pkg/ui/components"
+	. "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+const (
+	AdminTaskActionRetry   = "retry"
+	AdminTaskActionCancel  = "cancel"
+	AdminTaskActionDiscard = "discard"
+)
+
+type AdminTaskAction struct {
+	Action string  `form:"action" validate:"required,oneof=retry cancel discard"`
+	IDs    []int64 `form:"ids"`
+	form.Submission
+}
+
+type AdminTaskActionParams struct {
+	CanRetry   bool
+	CanCancel  bool
+	CanDiscard bool
+}
+
+func AdminTaskActionButtons(params AdminTaskActionParams) Node {
+	button := func(action, class, label string) Node {
+		return Button(
+			Class("button "+class),
+			Name("action"),
+			Value(action),
+			Text(label),
+		)
+	}
+
+	return ControlGroup(
+		If(params.CanRetry, button(AdminTaskActionRetry, "is-link", "Retry")),
+		If(params.CanCancel, button(AdminTaskActionCancel, "is-warning", "Cancel")),
+		If(params.CanDiscard, button(AdminTaskActionDiscard, "is-danger", "Discard")),
+	)
+}
+
+func AdminTask(r *ui.Request, action string, params AdminTaskActionParams) Node {
+	return Form(
+		Method(http.MethodPost),
+		Action(action),
+		AdminTaskActionButtons(params),
+		CSRF(r),
+	)
+}
@@ -22,8 +22,8 @@ func (f *Task) Render(r *ui.Request) Node {
 	return Form(
 		ID("task"),
 		Method(http.MethodPost),
-		Attr("hx-post", r.Path(routenames.TaskSubmit)),
-		FlashMessages(r),
+		HxBoost(),
+		Action(r.Path(routenames.TaskSubmit)),
 		InputField(InputFieldParams{
 			Form:      f,
 			FormField: "Delay",
@@ -149,6 +149,14 @@ func sidebarMenu(r *ui.Request) Node {
 				Class("menu-list"),
 				entityTypeLinks,
 			),
+			P(
+				Class("menu-label"),
+				Text("Monitoring"),
+			),
+			Ul(
+				Class("menu-list"),
+				MenuLink(r, "Tasks", routenames.AdminTasks),
+			),
 		}
 	}
 
@@ -0,0 +1,324 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/pager"
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
+	"github.com/edkadigital/startmeup/pkg/ui"
+	. "github.com/edkadigital/startmeup/pkg/ui/components"
+	"github.com/edkadigital/startmeup/pkg/ui/forms"
+	"github.com/edkadigital/startmeup/pkg/ui/layouts"
+	"github.com/labstack/echo/v4"
+	. "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/components"
+	. "maragu.dev/gomponents/html"
+)
+
+// adminTaskQueuesPollInterval is how often the queue depths are refreshed.
+const adminTaskQueuesPollInterval = 5 * time.Second
+
+func AdminTaskList(
+	ctx echo.Context,
+	filter riveradapter.JobFilter,
+	jobs []*riveradapter.Job,
+	depths []riveradapter.QueueDepth,
+	pgr pager.Pager,
+) error {
+	r := ui.NewRequest(ctx)
+	r.Title = "Tasks"
+
+	query := func(page int) url.Values {
+		q := url.Values{}
+		for k, v := range map[string]string{
+			"state": filter.State,
+			"kind":  filter.Kind,
+			"queue": filter.Queue,
+		} {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		if page > 1 {
+			q.Set(pager.QueryKey, fmt.Sprint(page))
+		}
+		return q
+	}
+
+	withQuery := func(path string, page int) string {
+		if q := query(page); len(q) > 0 {
+			return fmt.Sprintf("%s?%s", path, q.Encode())
+		}
+		return path
+	}
+
+	stateChoices := []Choice{{Value: "", Label: "All states"}}
+	for _, state := range riveradapter.JobStates() {
+		stateChoices = append(stateChoices, Choice{Value: state, Label: state})
+	}
+
+	filterForm := Form(
+		Method(http.MethodGet),
+		Action(r.Path(routenames.AdminTasks)),
+		HxBoost(),
+		Div(
+			Class("columns"),
+			Div(
+				Class("column"),
+				SelectList(OptionsParams{
+					Name:    "state",
+					Label:   "State",
+					Value:   filter.State,
+					Options: stateChoices,
+				}),
+			),
+			Div(
+				Class("column"),
+				InputField(InputFieldParams{
+					Name:      "kind",
+					InputType: "text",
+					Label:     "Kind",
+					Value:     filter.Kind,
+				}),
+			),
+			Div(
+				Class("column"),
+				InputField(InputFieldParams{
+					Name:      "queue",
+					InputType: "text",
+					Label:     "Queue",
+					Value:     filter.Queue,
+				}),
+			),
+		),
+		ControlGroup(
+			FormButton("is-link", "Filter"),
+			ButtonLink(r.Path(routenames.AdminTasks), "is-secondary", "Reset"),
+		),
+	)
+
+	genRows := func() Node {
+		g := make(Group, 0, len(jobs))
+		for _, job := range jobs {
+			g = append(g, Tr(
+				Td(
+					Input(
+						Type("checkbox"),
+						Name("ids"),
+						Value(fmt.Sprint(job.ID)),
+					),
+				),
+				Th(A(Href(r.Path(routenames.AdminTask, job.ID)), Text(fmt.Sprint(job.ID)))),
+				Td(Text(job.Kind)),
+				Td(Text(job.Queue)),
+				Td(adminTaskState(job.State)),
+				Td(Textf("%d / %d", job.Attempt, job.MaxAttempts)),
+				Td(Text(job.ScheduledAt.Format(time.DateTime))),
+				Td(Text(fmt.Sprint(len(job.Errors)))),
+			))
+		}
+		return g
+	}
+
+	jobList := Form(
+		Method(http.MethodPost),
+		Action(withQuery(r.Path(routenames.AdminTasksSubmit), pgr.Page)),
+		Table(
+			Class("table is-fullwidth"),
+			THead(
+				Tr(
+					Th(
+						Input(
+							Type("checkbox"),
+							Attr("@click", "document.querySelectorAll('input[name=ids]').forEach(el => el.checked = $el.checked)"),
+						),
+					),
+					Th(Text("ID")),
+					Th(Text("Kind")),
+					Th(Text("Queue")),
+					Th(Text("State")),
+					Th(Text("Attempts")),
+					Th(Text("Scheduled at")),
+					Th(Text("Errors")),
+				),
+			),
+			TBody(genRows()),
+		),
+		If(len(jobs) == 0, P(Class("has-text-centered"), Text("No tasks found."))),
+		forms.AdminTaskActionButtons(forms.AdminTaskActionParams{
+			CanRetry:   true,
+			CanCancel:  true,
+			CanDiscard: true,
+		}),
+		CSRF(r),
+	)
+
+	pagedHref := func(page int) string {
+		return withQuery(r.Path(routenames.AdminTasks), page)
+	}
+
+	return r.Render(layouts.Primary, Group{
+		adminTaskQueueDepths(r, depths),
+		filterForm,
+		Hr(),
+		jobList,
+		Nav(
+			Class("pagination"),
+			A(
+				Classes{
+					"pagination-previous": true,
+					"is-disabled":         pgr.IsBeginning(),
+				},
+				If(!pgr.IsBeginning(), Href(pagedHref(pgr.Page-1))),
+				Text("Previous page"),
+			),
+			A(
+				Classes{
+					"pagination-next": true,
+					"is-disabled":     pgr.IsEnd(),
+				},
+				If(!pgr.IsEnd(), Href(pagedHref(pgr.Page+1))),
+				Text("Next page"),
+			),
+		),
+	})
+}
+
+func AdminTaskQueues(ctx echo.Context, depths []riveradapter.QueueDepth) error {
+	r := ui.NewRequest(ctx)
+	r.Title = "Queues"
+
+	return r.Render(layouts.Primary, adminTaskQueueDepths(r, depths))
+}
+
+func AdminTask(ctx echo.Context, job *riveradapter.Job) error {
+	r := ui.NewRequest(ctx)
+	r.Title = fmt.Sprintf("Task %d", job.ID)
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(time.DateTime)
+	}
+
+	genErrors := func() Node {
+		g := make(Group, 0, len(job.Errors))
+		for _, e := range job.Errors {
+			g = append(g, Tr(
+				Td(Text(fmt.Sprint(e.Attempt))),
+				Td(Text(e.At.Format(time.DateTime))),
+				Td(
+					Text(e.Error),
+					If(e.Trace != "", Details(
+						Summary(Text("Trace")),
+						Pre(Text(e.Trace)),
+					)),
+				),
+			))
+		}
+		return g
+	}
+
+	return r.Render(layouts.Primary, Group{
+		Table(
+			Class("table"),
+			TBody(
+				Tr(Th(Text("Kind")), Td(Text(job.Kind))),
+				Tr(Th(Text("Queue")), Td(Text(job.Queue))),
+				Tr(Th(Text("State")), Td(adminTaskState(job.State))),
+				Tr(Th(Text("Priority")), Td(Text(fmt.Sprint(job.Priority)))),
+				Tr(Th(Text("Attempts")), Td(Textf("%d / %d", job.Attempt, job.MaxAttempts))),
+				Tr(Th(Text("Created at")), Td(Text(formatTime(&job.CreatedAt)))),
+				Tr(Th(Text("Scheduled at")), Td(Text(formatTime(&job.ScheduledAt)))),
+				Tr(Th(Text("Attempted at")), Td(Text(formatTime(job.AttemptedAt)))),
+				Tr(Th(Text("Finalized at")), Td(Text(formatTime(job.FinalizedAt)))),
+			),
+		),
+		H2(Class("subtitle"), Text("Arguments")),
+		Pre(Text(job.Args)),
+		H2(Class("subtitle mt-5"), Text("Metadata")),
+		Pre(Text(job.Metadata)),
+		H2(Class("subtitle mt-5"), Text("Errors")),
+		If(len(job.Errors) == 0, P(Text("No errors."))),
+		If(len(job.Errors) > 0, Table(
+			Class("table is-fullwidth"),
+			THead(
+				Tr(
+					Th(Text("Attempt")),
+					Th(Text("At")),
+					Th(Text("Error")),
+				),
+			),
+			TBody(genErrors()),
+		)),
+		forms.AdminTask(r, r.Path(routenames.AdminTaskSubmit, job.ID), forms.AdminTaskActionParams{
+			CanRetry:   job.CanRetry(),
+			CanCancel:  job.CanCancel(),
+			CanDiscard: job.CanDiscard(),
+		}),
+	})
+}
+
+func adminTaskQueueDepths(r *ui.Request, depths []riveradapter.QueueDepth) Node {
+	genRows := func() Node {
+		g := make(Group, 0, len(depths))
+		for _, d := range depths {
+			g = append(g, Tr(
+				Th(A(
+					Href(fmt.Sprintf("%s?queue=%s", r.Path(routenames.AdminTasks), url.QueryEscape(d.Queue))),
+					Text(d.Queue),
+				)),
+				Td(Text(fmt.Sprint(d.Available))),
+				Td(Text(fmt.Sprint(d.Scheduled))),
+				Td(Text(fmt.Sprint(d.Running))),
+				Td(Text(fmt.Sprint(d.Retryable))),
+				Td(Text(fmt.Sprint(d.Pending))),
+			))
+		}
+		return g
+	}
+
+	return Div(
+		ID("queue-depths"),
+		HxPoll(r.Path(routenames.AdminTaskQueues), adminTaskQueuesPollInterval),
+		Table(
+			Class("table is-fullwidth is-narrow"),
+			THead(
+				Tr(
+					Th(Text("Queue")),
+					Th(Text("Available")),
+					Th(Text("Scheduled")),
+					Th(Text("Running")),
+					Th(Text("Retryable")),
+					Th(Text("Pending")),
+				),
+			),
+			TBody(genRows()),
+		),
+		If(len(depths) == 0, P(Class("has-text-centered"), Text("All queues are empty."))),
+	)
+}
+
+func adminTaskState(state string) Node {
+	var class string
+
+	switch state {
+	case riveradapter.JobStateCompleted:
+		class = "is-success"
+	case riveradapter.JobStateRunning:
+		class = "is-info"
+	case riveradapter.JobStateRetryable, riveradapter.JobStateCancelled:
+		class = "is-warning"
+	case riveradapter.JobStateDiscarded:
+		class = "is-danger"
+	default:
+		class = "is-light"
+	}
+
+	return Span(Class("tag "+class), Text(state))
+}
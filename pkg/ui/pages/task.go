@@ -1,6 +1,11 @@
 package pages
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/edkadigital/startmeup/pkg/routenames"
+	"github.com/edkadigital/startmeup/pkg/tasks/riveradapter"
 	"github.com/edkadigital/startmeup/pkg/ui"
 	"github.com/edkadigital/startmeup/pkg/ui/components"
 	"github.com/edkadigital/startmeup/pkg/ui/forms"
@@ -10,23 +15,91 @@ import (
 	. "maragu.dev/gomponents/html"
 )
 
+// taskStatusPollInterval is how often the status of a task is refreshed while it is unfinished.
+const taskStatusPollInterval = time.Second
+
 func AddTask(ctx echo.Context, form *forms.Task) error {
 	r := ui.NewRequest(ctx)
 	r.Title = "Create a task"
 	r.Metatags.Description = "Test creating a task to see how it works."
 
 	g := Group{
-		Iff(r.Htmx.Target != "task", func() Node {
-			return components.Message(
-				"is-link",
-				"",
-				Group{
-					P(Raw("Submitting this form will create an <i>ExampleTask</i> in the task queue. After the specified delay, the message will be logged by the queue processor.")),
-					P(Text("See pkg/tasks and the README for more information.")),
-				})
-		}),
+		components.Message(
+			"is-link",
+			"",
+			Group{
+				P(Raw("Submitting this form will create an <i>ExampleTask</i> in the task queue. After the specified delay, the task works through a few steps, reporting its progress, and then the message will be logged by the queue processor.")),
+				P(Text("See pkg/tasks and the README for more information.")),
+			}),
 		form.Render(r),
 	}
 
 	return r.Render(layouts.Primary, g)
 }
+
+func TaskStatus(ctx echo.Context, job *riveradapter.Job) error {
+	r := ui.NewRequest(ctx)
+	r.Title = fmt.Sprintf("Task %d", job.ID)
+
+	// Polling requests only need the status itself.
+	if r.Htmx.Target == "task-status" {
+		return taskStatus(r, job).Render(ctx.Response().Writer)
+	}
+
+	return r.Render(layouts.Primary, Group{
+		taskStatus(r, job),
+		components.ButtonLink(r.Path(routenames.Task), "is-link mt-5", "Create another task"),
+	})
+}
+
+func taskStatus(r *ui.Request, job *riveradapter.Job) Node {
+	progress := job.Progress()
+
+	var (
+		class   string
+		message string
+	)
+	switch job.State {
+	case riveradapter.JobStateCompleted:
+		class, message = "is-success", "The task has completed."
+	case riveradapter.JobStateDiscarded, riveradapter.JobStateCancelled:
+		class, message = "is-danger", "The task has failed and will not be attempted again."
+	case riveradapter.JobStateRetryable:
+		class, message = "is-warning", "The task has failed and will be retried."
+	case riveradapter.JobStateRunning:
+		class, message = "is-info", "The task is running."
+	default:
+		class, message = "is-light", fmt.Sprintf("The task is waiting to run, at %s.", job.ScheduledAt.Format(time.DateTime))
+	}
+
+	// Errors can contain internal details, so only admins see them.
+	var lastError string
+	if len(job.Errors) > 0 && job.State != riveradapter.JobStateCompleted {
+		lastError = "Something went wrong while processing the task."
+		if r.IsAdmin {
+			lastError = job.Errors[len(job.Errors)-1].Error
+		}
+	}
+
+	return Div(
+		ID("task-status"),
+		If(!job.IsFinalized(), components.HxPoll(r.Path(routenames.TaskStatus, job.ID), taskStatusPollInterval)),
+		P(
+			Class("mb-3"),
+			Span(Class("tag "+class), Text(job.State)),
+			Text(" "+message),
+		),
+		Iff(progress != nil, func() Node {
+			return Group{
+				Progress(
+					Class("progress "+class),
+					Value(fmt.Sprint(progress.Percent)),
+					Max("100"),
+					Textf("%d%%", progress.Percent),
+				),
+				P(Textf("%d%% - %s", progress.Percent, progress.Message)),
+			}
+		}),
+		If(lastError != "", components.Message("is-danger mt-3", "Last error", P(Text(lastError)))),
+	)
+}